@@ -126,6 +126,7 @@ func New(address string, opts ...Opt) (*Client, error) {
 	if copts.services != nil {
 		c.services = *copts.services
 	}
+	c.credentialStore = copts.credentialStore
 	if address != "" {
 		backoffConfig := backoff.DefaultConfig
 		backoffConfig.MaxDelay = copts.timeout
@@ -202,6 +203,7 @@ func NewWithConn(conn *grpc.ClientConn, opts ...Opt) (*Client, error) {
 	if copts.services != nil {
 		c.services = *copts.services
 	}
+	c.credentialStore = copts.credentialStore
 	return c, nil
 }
 
@@ -215,11 +217,26 @@ type Client struct {
 	platform  platforms.MatchComparer
 	connector func() (*grpc.ClientConn, error)
 
+	// credentialStore, if set, authenticates the resolver constructed by
+	// defaultRemoteContext for every Fetch/Push call that does not
+	// override the resolver itself.
+	credentialStore CredentialStore
+
+	sessionsMu sync.Once
+	sessions   *SessionRegistry
+
 	// this should only be accessed via defaultRuntime()
 	runtime struct {
 		value string
 		mut   sync.Mutex
 	}
+
+	// runtimeFeaturesCache memoizes RuntimeFeatures lookups for this
+	// Client; scoped per-Client (rather than package-level) so two
+	// Clients pointed at different daemons, or the same daemon before and
+	// after a runtime upgrade, don't read back each other's cached
+	// Features.
+	runtimeFeaturesCache sync.Map // map[string]*RuntimeFeatures
 }
 
 // Reconnect re-establishes the GRPC connection to the containerd daemon
@@ -431,23 +448,66 @@ type RemoteContext struct {
 	// ChildLabelMap sets the labels used to reference child objects in the content
 	// store. By default, all GC reference labels will be set for all fetched content.
 	ChildLabelMap func(ocispec.Descriptor) []string
+
+	// TrustPolicy, if set, verifies the descriptor resolved by Fetch/Pull
+	// against signed TUF/Notary trust metadata before any blob transfer
+	// begins, and signs the manifest uploaded by Push.
+	TrustPolicy *TrustPolicy
+
+	// CacheImports are resolved before Fetch to pre-populate the content
+	// store with layers already known to be available, so transfer of
+	// those layers is skipped.
+	CacheImports []CacheImport
+
+	// CacheExports run after Push and record or push a cache config
+	// describing the pushed image's layers (see CacheExport).
+	CacheExports []CacheExport
+
+	// ResumeFrom, when non-empty, makes Fetch resume layer downloads from
+	// the content store's existing ingest state for each blob, skipping
+	// already-downloaded bytes. Its value is not otherwise consulted: the
+	// ingest state is found by each blob's own ref key, not through the
+	// reference named here. Leave empty to always download from the start.
+	ResumeFrom string
+
+	// SessionID, when set by WithSession, is added to the gRPC metadata of
+	// the requests Fetch and Push make, so the daemon can tell which
+	// session a request belongs to.
+	SessionID string
+
+	// ReferrerSubject, set by WithReferrerOf, makes Push rewrite the
+	// manifest/index it uploads to carry this descriptor as its "subject"
+	// field, and additionally push the result under the registry's
+	// "sha256-<hex>.sig" fallback tag for the subject.
+	ReferrerSubject *ocispec.Descriptor
+
+	// ref is the reference passed to Fetch, stashed here so handlers
+	// installed through HandlerWrapper (e.g. WithFetchReferrers) can look
+	// up further objects in the same repository without needing a bare
+	// digest re-resolved with no registry/repository context.
+	ref string
 }
 
-func defaultRemoteContext() *RemoteContext {
+func defaultRemoteContext(c *Client) *RemoteContext {
+	resolverOpts := docker.ResolverOptions{}
+	if c.credentialStore != nil {
+		resolverOpts.Authorizer = authorizerFromCredentialStore(c.credentialStore)
+	}
 	return &RemoteContext{
-		Resolver: docker.NewResolver(docker.ResolverOptions{}),
+		Resolver: docker.NewResolver(resolverOpts),
 	}
 }
 
 // Fetch downloads the provided content into containerd's content store
 // and returns a non-platform specific image reference
 func (c *Client) Fetch(ctx context.Context, ref string, opts ...RemoteOpt) (images.Image, error) {
-	fetchCtx := defaultRemoteContext()
+	fetchCtx := defaultRemoteContext(c)
 	for _, o := range opts {
 		if err := o(c, fetchCtx); err != nil {
 			return images.Image{}, err
 		}
 	}
+	fetchCtx.ref = ref
 
 	if fetchCtx.Unpack {
 		return images.Image{}, fmt.Errorf("unpack on fetch not supported, try pull: %w", errdefs.ErrNotImplemented)
@@ -466,12 +526,28 @@ func (c *Client) Fetch(ctx context.Context, ref string, opts ...RemoteOpt) (imag
 		}
 	}
 
+	if fetchCtx.TrustPolicy != nil {
+		fetchCtx.Resolver = &trustVerifyingResolver{Resolver: fetchCtx.Resolver, policy: fetchCtx.TrustPolicy}
+	}
+
+	if fetchCtx.ResumeFrom != "" {
+		fetchCtx.Resolver = &resumeWrappingResolver{Resolver: fetchCtx.Resolver, store: c.ContentStore()}
+	}
+
+	ctx = outgoingSessionContext(ctx, fetchCtx.SessionID)
+
 	ctx, done, err := c.WithLease(ctx)
 	if err != nil {
 		return images.Image{}, err
 	}
 	defer done(ctx)
 
+	if len(fetchCtx.CacheImports) > 0 {
+		if err := c.importCaches(ctx, fetchCtx); err != nil {
+			return images.Image{}, err
+		}
+	}
+
 	img, err := c.fetch(ctx, fetchCtx, ref, 0)
 	if err != nil {
 		return images.Image{}, err
@@ -481,7 +557,7 @@ func (c *Client) Fetch(ctx context.Context, ref string, opts ...RemoteOpt) (imag
 
 // Push uploads the provided content to a remote resource
 func (c *Client) Push(ctx context.Context, ref string, desc ocispec.Descriptor, opts ...RemoteOpt) error {
-	pushCtx := defaultRemoteContext()
+	pushCtx := defaultRemoteContext(c)
 	for _, o := range opts {
 		if err := o(c, pushCtx); err != nil {
 			return err
@@ -499,11 +575,27 @@ func (c *Client) Push(ctx context.Context, ref string, desc ocispec.Descriptor,
 		}
 	}
 
+	// signRef keeps the ref as the caller passed it (typically tag-qualified)
+	// for TrustPolicy signing, since the digest annotated onto ref below is
+	// for addressing this specific push and would otherwise make every
+	// signed target indistinguishable by digest alone.
+	signRef := ref
+
+	if pushCtx.ReferrerSubject != nil {
+		rewritten, err := c.withReferrerSubject(ctx, desc, *pushCtx.ReferrerSubject)
+		if err != nil {
+			return fmt.Errorf("setting referrer subject on %s: %w", desc.Digest, err)
+		}
+		desc = rewritten
+	}
+
 	// Annotate ref with digest to push only push tag for single digest
 	if !strings.Contains(ref, "@") {
 		ref = ref + "@" + desc.Digest.String()
 	}
 
+	ctx = outgoingSessionContext(ctx, pushCtx.SessionID)
+
 	pusher, err := pushCtx.Resolver.Pusher(ctx, ref)
 	if err != nil {
 		return err
@@ -528,7 +620,33 @@ func (c *Client) Push(ctx context.Context, ref string, desc ocispec.Descriptor,
 		limiter = semaphore.NewWeighted(int64(pushCtx.MaxConcurrentUploadedLayers))
 	}
 
-	return remotes.PushContent(ctx, pusher, desc, c.ContentStore(), limiter, pushCtx.PlatformMatcher, wrapper)
+	if err := remotes.PushContent(ctx, pusher, desc, c.ContentStore(), limiter, pushCtx.PlatformMatcher, wrapper); err != nil {
+		return err
+	}
+
+	if pushCtx.ReferrerSubject != nil {
+		fallbackRef := fallbackTagRef(signRef, *pushCtx.ReferrerSubject)
+		fallbackPusher, err := pushCtx.Resolver.Pusher(ctx, fallbackRef)
+		if err != nil {
+			return fmt.Errorf("resolving referrer fallback tag %s: %w", fallbackRef, err)
+		}
+		if err := remotes.PushContent(ctx, fallbackPusher, desc, c.ContentStore(), limiter, pushCtx.PlatformMatcher, wrapper); err != nil {
+			return fmt.Errorf("pushing referrer fallback tag %s: %w", fallbackRef, err)
+		}
+	}
+
+	if pushCtx.TrustPolicy != nil {
+		if err := pushCtx.TrustPolicy.signManifest(ctx, signRef, desc); err != nil {
+			return fmt.Errorf("signing pushed manifest: %w", err)
+		}
+	}
+
+	if len(pushCtx.CacheExports) > 0 {
+		if err := c.exportCaches(ctx, pushCtx, signRef, desc); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // GetImage returns an existing image
@@ -0,0 +1,273 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/containerd/containerd/v2/core/remotes/docker"
+)
+
+// credentialHelperTimeout bounds how long a docker-credential-<store>
+// helper invocation is allowed to run before the lookup is treated as a
+// failure.
+const credentialHelperTimeout = 10 * time.Second
+
+// CredentialStore resolves registry credentials for a server URL, mirroring
+// the docker-credential-helpers protocol so containerd clients can reuse
+// credentials already configured for the docker CLI.
+type CredentialStore interface {
+	// Get returns the username/secret pair (or identity token) configured
+	// for serverURL. An empty username with a non-empty identityToken
+	// indicates an OAuth2 identity token should be used instead of basic
+	// auth.
+	Get(serverURL string) (username, secret, identityToken string, err error)
+}
+
+// dockerConfigFile is the subset of ~/.docker/config.json this package
+// understands.
+type dockerConfigFile struct {
+	Auths       map[string]dockerConfigAuth `json:"auths"`
+	CredsStore  string                      `json:"credsStore"`
+	CredHelpers map[string]string           `json:"credHelpers"`
+}
+
+type dockerConfigAuth struct {
+	Auth          string `json:"auth"`
+	IdentityToken string `json:"identitytoken"`
+}
+
+// dockerConfigStore reads credentials from ~/.docker/config.json, spawning
+// the configured docker-credential-<store> helper for servers that delegate
+// to one, and caching successful lookups for the lifetime of the store.
+type dockerConfigStore struct {
+	path string
+
+	mu    sync.Mutex
+	cache map[string]cachedCredential
+	cfg   *dockerConfigFile
+}
+
+type cachedCredential struct {
+	username, secret, identityToken string
+}
+
+// NewDockerConfigCredentialStore returns a CredentialStore backed by the
+// docker CLI configuration file at path. If path is empty, it defaults to
+// "~/.docker/config.json".
+func NewDockerConfigCredentialStore(path string) (CredentialStore, error) {
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("resolving docker config path: %w", err)
+		}
+		path = filepath.Join(home, ".docker", "config.json")
+	}
+	return &dockerConfigStore{path: path, cache: make(map[string]cachedCredential)}, nil
+}
+
+func (s *dockerConfigStore) config() (*dockerConfigFile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cfg != nil {
+		return s.cfg, nil
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			s.cfg = &dockerConfigFile{}
+			return s.cfg, nil
+		}
+		return nil, err
+	}
+	var cfg dockerConfigFile
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", s.path, err)
+	}
+	s.cfg = &cfg
+	return s.cfg, nil
+}
+
+func (s *dockerConfigStore) Get(serverURL string) (string, string, string, error) {
+	s.mu.Lock()
+	if cred, ok := s.cache[serverURL]; ok {
+		s.mu.Unlock()
+		return cred.username, cred.secret, cred.identityToken, nil
+	}
+	s.mu.Unlock()
+
+	cfg, err := s.config()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	helper := cfg.CredHelpers[serverURL]
+	if helper == "" {
+		helper = cfg.CredsStore
+	}
+
+	var username, secret, identityToken string
+	if helper != "" {
+		username, secret, identityToken, err = invokeCredentialHelper(helper, serverURL)
+		if err != nil {
+			return "", "", "", err
+		}
+	} else if auth, ok := cfg.Auths[serverURL]; ok {
+		username, secret, err = decodeBasicAuth(auth.Auth)
+		if err != nil {
+			return "", "", "", err
+		}
+		identityToken = auth.IdentityToken
+	}
+
+	s.mu.Lock()
+	s.cache[serverURL] = cachedCredential{username, secret, identityToken}
+	s.mu.Unlock()
+	return username, secret, identityToken, nil
+}
+
+func decodeBasicAuth(encoded string) (username, secret string, err error) {
+	if encoded == "" {
+		return "", "", nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", fmt.Errorf("decoding auth entry: %w", err)
+	}
+	parts := bytes.SplitN(decoded, []byte(":"), 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("malformed auth entry")
+	}
+	return string(parts[0]), string(parts[1]), nil
+}
+
+// credentialHelperResponse follows the documented
+// docker-credential-helpers "get" protocol: a server URL written to stdin,
+// a JSON document with ServerURL/Username/Secret returned on stdout.
+type credentialHelperResponse struct {
+	ServerURL string `json:"ServerURL"`
+	Username  string `json:"Username"`
+	Secret    string `json:"Secret"`
+}
+
+// invokeCredentialHelper runs "docker-credential-<helper> get", writing
+// serverURL to its stdin and parsing the JSON credential from its stdout.
+// A username of "<token>" signals that Secret carries an OAuth2 identity
+// token rather than a password.
+func invokeCredentialHelper(helper, serverURL string) (username, secret, identityToken string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), credentialHelperTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "docker-credential-"+helper, "get")
+	cmd.Stdin = bytes.NewBufferString(serverURL)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", "", "", fmt.Errorf("docker-credential-%s get %s: %w: %s", helper, serverURL, err, stderr.String())
+	}
+
+	var resp credentialHelperResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return "", "", "", fmt.Errorf("parsing docker-credential-%s response: %w", helper, err)
+	}
+	if resp.Username == "<token>" {
+		return "", "", resp.Secret, nil
+	}
+	return resp.Username, resp.Secret, "", nil
+}
+
+// staticCredentialStore always returns the same credential, used as a
+// fallback when no docker config or credential helper is available.
+type staticCredentialStore struct {
+	username, secret, identityToken string
+}
+
+// NewStaticCredentialStore returns a CredentialStore that always resolves
+// to the given username/secret pair, regardless of server URL.
+func NewStaticCredentialStore(username, secret string) CredentialStore {
+	return &staticCredentialStore{username: username, secret: secret}
+}
+
+func (s *staticCredentialStore) Get(string) (string, string, string, error) {
+	return s.username, s.secret, s.identityToken, nil
+}
+
+// authorizerFromCredentialStore builds a docker.Authorizer that resolves
+// credentials through cs, translating an identity token response into the
+// OAuth2 refresh-token flow docker.NewDockerAuthorizer already implements
+// for WithAuthCreds callers.
+func authorizerFromCredentialStore(cs CredentialStore) docker.Authorizer {
+	return docker.NewDockerAuthorizer(docker.WithAuthCreds(func(serverURL string) (string, string, error) {
+		username, secret, identityToken, err := cs.Get(serverURL)
+		if err != nil {
+			return "", "", err
+		}
+		if identityToken != "" {
+			// Surface the identity token as the secret half of the pair;
+			// docker.WithAuthCreds treats an empty username together with
+			// a non-empty secret as an identity token per its refresh flow.
+			return "", identityToken, nil
+		}
+		return username, secret, nil
+	}))
+}
+
+// WithCredentialStore configures the default resolver constructed by
+// defaultRemoteContext to authenticate through cs.
+func WithCredentialStore(cs CredentialStore) Opt {
+	return func(c *clientOpts) error {
+		c.credentialStore = cs
+		return nil
+	}
+}
+
+// WithDockerConfigCredentials is a convenience wrapper around
+// WithCredentialStore that reads credentials from the docker CLI config at
+// path ("" for the default "~/.docker/config.json"), including any
+// configured docker-credential-<store> helper.
+func WithDockerConfigCredentials(path string) Opt {
+	return func(c *clientOpts) error {
+		cs, err := NewDockerConfigCredentialStore(path)
+		if err != nil {
+			return err
+		}
+		c.credentialStore = cs
+		return nil
+	}
+}
+
+// WithResolverCredentials configures this RemoteContext's resolver to
+// authenticate through cs for this call only.
+func WithResolverCredentials(cs CredentialStore) RemoteOpt {
+	return func(_ *Client, c *RemoteContext) error {
+		c.Resolver = docker.NewResolver(docker.ResolverOptions{
+			Authorizer: authorizerFromCredentialStore(cs),
+		})
+		return nil
+	}
+}
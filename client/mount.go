@@ -0,0 +1,109 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/containerd/containerd/v2/core/mount"
+	"github.com/containerd/containerd/v2/core/snapshots"
+	"github.com/containerd/errdefs"
+)
+
+// Mountable is an explicit handle on a snapshot's mounts, giving
+// snapshotter plugins (remote snapshotters, FUSE-based ones, stargz) a hook
+// to do work when the mount is really being consumed versus just
+// enumerated, and a matching hook to release whatever that work set up.
+type Mountable interface {
+	// Mount returns the mounts to apply, performing any lazy hydration
+	// the snapshotter needs first.
+	Mount() ([]mount.Mount, error)
+	// Release tells the snapshotter the mounts are no longer needed,
+	// e.g. to drop a reference count or clean up an overlay work dir.
+	// Callers must call mount.UnmountAll themselves first; Release only
+	// releases the snapshotter-side resources backing the mount.
+	Release() error
+}
+
+// activator is implemented by snapshotter proxies that support the
+// Activate/Deactivate RPCs. Snapshotters that don't implement it are
+// wrapped in a no-op activator by SnapshotMountable.
+type activator interface {
+	Activate(ctx context.Context, key string) error
+	Deactivate(ctx context.Context, key string) error
+}
+
+// mountable is the default Mountable implementation, wrapping a
+// snapshots.Snapshotter's Mounts/View/Prepare result with an Activate call
+// on Mount and a Deactivate call on Release when the snapshotter supports
+// them.
+type mountable struct {
+	ctx         context.Context
+	snapshotter snapshots.Snapshotter
+	key         string
+	mounts      []mount.Mount
+}
+
+func (m *mountable) Mount() ([]mount.Mount, error) {
+	if a, ok := m.snapshotter.(activator); ok {
+		if err := a.Activate(m.ctx, m.key); err != nil {
+			return nil, err
+		}
+	}
+	return m.mounts, nil
+}
+
+func (m *mountable) Release() error {
+	if a, ok := m.snapshotter.(activator); ok {
+		return a.Deactivate(m.ctx, m.key)
+	}
+	return nil
+}
+
+// SnapshotMountable returns a Mountable for the given snapshotter and key,
+// preparing or looking up the snapshot's mounts without requiring the
+// caller to know whether the snapshotter needs an explicit activation step.
+//
+// key must already name an active or view snapshot transaction for Mounts
+// to succeed; if it doesn't (e.g. key names a committed snapshot with no
+// open transaction), SnapshotMountable opens a new read-only view
+// transaction, under a freshly generated key, rooted at parent - the same
+// chain-ID parent a read-only consumer of a committed snapshot would pass
+// to Snapshotter.View. key itself is never reused as a transaction name:
+// it already names the committed snapshot that made Mounts fail, and
+// View would just fail again with the key already existing.
+func (c *Client) SnapshotMountable(ctx context.Context, snapshotterName, key, parent string) (Mountable, error) {
+	sn, err := c.getSnapshotter(ctx, snapshotterName)
+	if err != nil {
+		return nil, err
+	}
+	mounts, err := sn.Mounts(ctx, key)
+	if err != nil {
+		if !errdefs.IsNotFound(err) {
+			return nil, err
+		}
+		viewKey := key + "-view-" + uuid.New().String()
+		mounts, err = sn.View(ctx, viewKey, parent)
+		if err != nil {
+			return nil, err
+		}
+		return &mountable{ctx: ctx, snapshotter: sn, key: viewKey, mounts: mounts}, nil
+	}
+	return &mountable{ctx: ctx, snapshotter: sn, key: key, mounts: mounts}, nil
+}
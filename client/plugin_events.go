@@ -0,0 +1,177 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"time"
+
+	apitypes "github.com/containerd/containerd/api/types"
+)
+
+// defaultPluginEventsPollInterval is how often PluginEvents polls
+// IntrospectionService().Plugins for state changes.
+const defaultPluginEventsPollInterval = 2 * time.Second
+
+// PluginEventKind identifies the kind of state transition a plugin went
+// through.
+type PluginEventKind string
+
+const (
+	// PluginEventLoaded marks a plugin whose package has been loaded but
+	// whose InitFn has not run yet. PluginEvents does not emit it.
+	PluginEventLoaded PluginEventKind = "loaded"
+	// PluginEventReady fires once a plugin's InitFn has returned
+	// successfully and it is available to other plugins/clients.
+	PluginEventReady PluginEventKind = "ready"
+	// PluginEventFailed marks a plugin whose InitFn returned an error.
+	// PluginEvents does not emit it.
+	PluginEventFailed PluginEventKind = "failed"
+	// PluginEventUnloaded fires when a plugin is torn down, e.g. during
+	// daemon shutdown.
+	PluginEventUnloaded PluginEventKind = "unloaded"
+	// PluginEventCapabilitiesChanged fires when a running plugin's
+	// reported capabilities change, e.g. a remote snapshotter gaining or
+	// losing a backing store.
+	PluginEventCapabilitiesChanged PluginEventKind = "capabilities-changed"
+)
+
+// PluginEvent describes a single plugin state transition, streamed by
+// Client.PluginEvents so external controllers can react without polling
+// IntrospectionService().Plugins themselves.
+type PluginEvent struct {
+	Kind         PluginEventKind
+	Type         string
+	ID           string
+	Platforms    []apitypes.Platform
+	Capabilities []string
+}
+
+// PluginEvents reports plugin lifecycle transitions, optionally narrowed by
+// the same filter expression grammar used by IntrospectionService().Plugins
+// (e.g. "type==io.containerd.snapshotter.v1"). There is no push-based plugin
+// event bus on the daemon side, so this polls Plugins() at
+// defaultPluginEventsPollInterval and diffs successive snapshots, reporting
+// a plugin's first appearance as PluginEventReady, a disappearance as
+// PluginEventUnloaded, and a changed Capabilities list as
+// PluginEventCapabilitiesChanged. PluginEventLoaded and PluginEventFailed
+// are defined for callers that want to match on them but are never emitted
+// by this implementation, since Plugins() only reports on plugins the
+// daemon has already finished initializing.
+//
+// The returned error channel receives a single error when polling stops
+// (the error from Plugins, or ctx.Err() once ctx is done) and is then
+// closed.
+func (c *Client) PluginEvents(ctx context.Context, filters ...string) (<-chan PluginEvent, <-chan error) {
+	events := make(chan PluginEvent)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(events)
+		defer close(errs)
+
+		ticker := time.NewTicker(defaultPluginEventsPollInterval)
+		defer ticker.Stop()
+
+		var prev map[string]*apitypes.Plugin
+		for {
+			resp, err := c.IntrospectionService().Plugins(ctx, filters...)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			cur := make(map[string]*apitypes.Plugin, len(resp.Plugins))
+			for _, p := range resp.Plugins {
+				cur[p.Type+"/"+p.ID] = p
+			}
+
+			for key, p := range cur {
+				old, existed := prev[key]
+				switch {
+				case !existed:
+					if !sendPluginEvent(ctx, events, pluginEvent(PluginEventReady, p)) {
+						errs <- ctx.Err()
+						return
+					}
+				case !stringSliceEqual(old.Capabilities, p.Capabilities):
+					if !sendPluginEvent(ctx, events, pluginEvent(PluginEventCapabilitiesChanged, p)) {
+						errs <- ctx.Err()
+						return
+					}
+				}
+			}
+			for key, p := range prev {
+				if _, ok := cur[key]; !ok {
+					if !sendPluginEvent(ctx, events, pluginEvent(PluginEventUnloaded, p)) {
+						errs <- ctx.Err()
+						return
+					}
+				}
+			}
+			prev = cur
+
+			select {
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return events, errs
+}
+
+func pluginEvent(kind PluginEventKind, p *apitypes.Plugin) PluginEvent {
+	return PluginEvent{
+		Kind:         kind,
+		Type:         p.Type,
+		ID:           p.ID,
+		Platforms:    toPlatformTypes(p.Platforms),
+		Capabilities: p.Capabilities,
+	}
+}
+
+func sendPluginEvent(ctx context.Context, events chan<- PluginEvent, e PluginEvent) bool {
+	select {
+	case events <- e:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func toPlatformTypes(pt []*apitypes.Platform) []apitypes.Platform {
+	out := make([]apitypes.Platform, len(pt))
+	for i, p := range pt {
+		out[i] = *p
+	}
+	return out
+}
+
+func stringSliceEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
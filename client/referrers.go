@@ -0,0 +1,278 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/core/images"
+	"github.com/containerd/containerd/v2/core/remotes"
+	"github.com/containerd/errdefs"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// cosignSignatureArtifactType is the artifactType cosign uses for the
+// signatures it attaches as referrers.
+const cosignSignatureArtifactType = "application/vnd.dev.cosign.artifact.sig.v1+json"
+
+// Referrers returns the descriptors of every artifact referring to the
+// manifest at ref, optionally restricted to the given artifact types. It
+// first tries the OCI Distribution Spec 1.1 referrers API and falls back
+// to the "sha256-<hex>.sig" tag-schema discovery method for registries that
+// don't implement it yet, merging the results.
+func (c *Client) Referrers(ctx context.Context, ref string, artifactTypes ...string) ([]ocispec.Descriptor, error) {
+	fetchCtx := defaultRemoteContext(c)
+	return referrersFor(ctx, fetchCtx.Resolver, ref, artifactTypes)
+}
+
+// referrersFor runs the referrers lookup against an already-built resolver,
+// factored out of Client.Referrers so callers that already hold a resolver
+// scoped to the right repository (such as fetchReferrersInto, reusing the
+// pull's own resolver) don't have to re-resolve through a fresh,
+// unauthenticated one.
+func referrersFor(ctx context.Context, resolver remotes.Resolver, ref string, artifactTypes []string) ([]ocispec.Descriptor, error) {
+	_, subject, err := resolver.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	fetcher, err := resolver.Fetcher(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []ocispec.Descriptor
+	if rf, ok := fetcher.(referrersFetcher); ok {
+		index, err := rf.FetchReferrers(ctx, subject)
+		if err != nil && !errdefs.IsNotImplemented(err) {
+			return nil, fmt.Errorf("fetching referrers for %s: %w", ref, err)
+		}
+		if err == nil {
+			results = append(results, filterByArtifactType(index.Manifests, artifactTypes)...)
+		}
+	}
+
+	tagRef := fallbackTagRef(ref, subject)
+	if _, desc, err := resolver.Resolve(ctx, tagRef); err == nil {
+		for _, d := range filterByArtifactType([]ocispec.Descriptor{desc}, artifactTypes) {
+			seen := false
+			for _, r := range results {
+				if r.Digest == d.Digest {
+					seen = true
+					break
+				}
+			}
+			if !seen {
+				results = append(results, d)
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// referrersFetcher is implemented by remotes.Fetcher implementations (such
+// as the docker resolver's) that support the OCI Distribution Spec 1.1
+// referrers API.
+type referrersFetcher interface {
+	FetchReferrers(ctx context.Context, subject ocispec.Descriptor) (ocispec.Index, error)
+}
+
+func filterByArtifactType(descs []ocispec.Descriptor, artifactTypes []string) []ocispec.Descriptor {
+	if len(artifactTypes) == 0 {
+		return descs
+	}
+	var out []ocispec.Descriptor
+	for _, d := range descs {
+		for _, t := range artifactTypes {
+			if d.ArtifactType == t {
+				out = append(out, d)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// digestRef replaces ref's tag or digest suffix with dgst, preserving its
+// host and repository so a lookup for a specific digest stays scoped to the
+// repository the caller is already pulling from.
+func digestRef(ref string, dgst digest.Digest) string {
+	name, _ := refTarget(ref)
+	return fmt.Sprintf("%s@%s", name, dgst.String())
+}
+
+// fallbackTagRef builds the "sha256-<hex>.sig" style tag used by registries
+// that predate the referrers API, as originally popularized by cosign.
+func fallbackTagRef(ref string, subject ocispec.Descriptor) string {
+	name, _ := refTarget(ref)
+	tag := strings.ReplaceAll(subject.Digest.String(), ":", "-")
+	return fmt.Sprintf("%s:%s.sig", name, tag)
+}
+
+// WithFetchReferrers walks the referrers index of the manifest pulled by
+// Fetch and pulls every matching subject artifact (signatures, SBOMs,
+// attestations) into the content store, linked back to the subject via GC
+// reference labels so they're retained together.
+func WithFetchReferrers(artifactTypes ...string) RemoteOpt {
+	return func(c *Client, rc *RemoteContext) error {
+		prev := rc.HandlerWrapper
+		rc.HandlerWrapper = func(h images.Handler) images.Handler {
+			if prev != nil {
+				h = prev(h)
+			}
+			return images.Handlers(h, images.HandlerFunc(func(ctx context.Context, desc ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+				if !images.IsManifestType(desc.MediaType) && !images.IsIndexType(desc.MediaType) {
+					return nil, nil
+				}
+				if err := fetchReferrersInto(ctx, c, rc, desc, artifactTypes); err != nil {
+					return nil, fmt.Errorf("fetching referrers of %s: %w", desc.Digest, err)
+				}
+				return nil, nil
+			}))
+		}
+		return nil
+	}
+}
+
+func fetchReferrersInto(ctx context.Context, c *Client, remoteCtx *RemoteContext, subject ocispec.Descriptor, artifactTypes []string) error {
+	// subject.Digest.String() alone has no host or repository, so resolving
+	// it as a reference fails; carry the repository from the pull's own ref
+	// (stashed on the RemoteContext by Fetch) and reuse the pull's resolver
+	// rather than building a fresh, unauthenticated one.
+	subjectRef := subject.Digest.String()
+	if remoteCtx.ref != "" {
+		subjectRef = digestRef(remoteCtx.ref, subject.Digest)
+	}
+
+	referrers, err := referrersFor(ctx, remoteCtx.Resolver, subjectRef, artifactTypes)
+	if err != nil {
+		return err
+	}
+	fetcher, err := remoteCtx.Resolver.Fetcher(ctx, subjectRef)
+	if err != nil {
+		return err
+	}
+	store := c.ContentStore()
+	for i, desc := range referrers {
+		body, err := fetcher.Fetch(ctx, desc)
+		if err != nil {
+			return fmt.Errorf("fetching referrer %s: %w", desc.Digest, err)
+		}
+		_, err = writeContent(ctx, store, desc.MediaType, desc.Digest.String(), body)
+		body.Close()
+		if err != nil && !errdefs.IsAlreadyExists(err) {
+			return err
+		}
+
+		// GC reachability flows forward along gc.ref.* labels: rooting the
+		// referrer requires the label on content that's already reachable
+		// (the subject, kept alive by the image) pointing at the referrer,
+		// not the other way around. A label on the referrer pointing at the
+		// subject would only keep the subject alive if the referrer were
+		// already reachable, which it never is on its own.
+		key := fmt.Sprintf("containerd.io/gc.ref.content.referrer.%d", i)
+		if err := addContentLabel(ctx, store, subject.Digest, key, desc.Digest.String()); err != nil {
+			return fmt.Errorf("rooting referrer %s on subject %s: %w", desc.Digest, subject.Digest, err)
+		}
+	}
+	return nil
+}
+
+// addContentLabel merges a single label onto the existing content.Info for
+// dgst, so referrers fetched after the subject manifest is already in the
+// store get rooted by it instead of requiring the subject to be rewritten.
+func addContentLabel(ctx context.Context, store content.Store, dgst digest.Digest, key, value string) error {
+	info, err := store.Info(ctx, dgst)
+	if err != nil {
+		return err
+	}
+	if info.Labels == nil {
+		info.Labels = make(map[string]string, 1)
+	}
+	info.Labels[key] = value
+	_, err = store.Update(ctx, info, "labels."+key)
+	return err
+}
+
+// WithReferrerOf makes Push set subject as the pushed manifest's OCI
+// "subject" field and additionally push the result under the
+// "sha256-<hex>.sig" fallback tag so registries without the referrers API
+// still expose the relationship.
+func WithReferrerOf(subject ocispec.Descriptor) RemoteOpt {
+	return func(_ *Client, rc *RemoteContext) error {
+		rc.ReferrerSubject = &subject
+		return nil
+	}
+}
+
+// withReferrerSubject rewrites the manifest or index at desc to carry
+// subject as its OCI "subject" field and writes the result to the content
+// store under a new ref, returning the descriptor of the rewritten content.
+// Setting Subject changes the content, so it necessarily produces a new
+// digest distinct from desc.Digest.
+func (c *Client) withReferrerSubject(ctx context.Context, desc, subject ocispec.Descriptor) (ocispec.Descriptor, error) {
+	store := c.ContentStore()
+	p, err := content.ReadBlob(ctx, store, desc)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("reading %s: %w", desc.Digest, err)
+	}
+
+	switch {
+	case images.IsIndexType(desc.MediaType):
+		var index ocispec.Index
+		if err := json.Unmarshal(p, &index); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		index.Subject = &subject
+		data, err := json.Marshal(index)
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		return writeContent(ctx, store, desc.MediaType, "referrer-subject-"+desc.Digest.String(), bytes.NewReader(data))
+	case images.IsManifestType(desc.MediaType):
+		var manifest ocispec.Manifest
+		if err := json.Unmarshal(p, &manifest); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		manifest.Subject = &subject
+		data, err := json.Marshal(manifest)
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		return writeContent(ctx, store, desc.MediaType, "referrer-subject-"+desc.Digest.String(), bytes.NewReader(data))
+	default:
+		return ocispec.Descriptor{}, fmt.Errorf("media type %s cannot carry a referrer subject: %w", desc.MediaType, errdefs.ErrNotImplemented)
+	}
+}
+
+func mergeLabels(base, extra map[string]string) map[string]string {
+	out := make(map[string]string, len(base)+len(extra))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range extra {
+		out[k] = v
+	}
+	return out
+}
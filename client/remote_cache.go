@@ -0,0 +1,242 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/core/images"
+	"github.com/containerd/containerd/v2/core/remotes"
+	"github.com/containerd/errdefs"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// cacheConfigMediaType is the media type of a remote cache manifest,
+// following BuildKit's inline/registry cache exporter convention.
+const cacheConfigMediaType = "application/vnd.buildkit.cacheconfig.v0"
+
+// cacheManifestsAnnotation is set on a pushed image's manifest when its
+// cache config is inlined rather than pushed as a separate manifest.
+const cacheManifestsAnnotation = "containerd.io/cache.manifests"
+
+// CacheLayer describes one layer entry in a CacheConfig, pointing at the
+// content-store blob for the layer and, for non-base layers, the index of
+// its parent within the same CacheConfig.
+type CacheLayer struct {
+	Blob        ocispec.Descriptor `json:"blob"`
+	ParentIndex int                `json:"parentIndex"`
+	Annotations map[string]string  `json:"annotations,omitempty"`
+}
+
+// CacheConfig is the document published by a CacheExport and consumed by a
+// CacheImport. It lists the content-store blobs of an image's manifest
+// layers so a later Fetch can skip already-cached layers.
+type CacheConfig struct {
+	Layers []CacheLayer `json:"layers"`
+}
+
+// CacheImport resolves a manifest at Ref whose media type is the BuildKit
+// cache config (or its OCI referrer variant) and pre-populates the local
+// content store with its layer blobs, each written under its blob digest,
+// so that a subsequent Fetch/unpack can skip blobs it already has. No chain
+// IDs are computed.
+type CacheImport struct {
+	// Ref is the reference to the cache manifest, e.g. "registry/repo:buildcache".
+	Ref string
+}
+
+// CacheExport runs after Client.Push and builds a CacheConfig document
+// derived from the pushed image, either recording it as an annotation on
+// the local image record or pushing it as a separate cache manifest.
+type CacheExport struct {
+	// Inline, when true, records the cache config as an annotation on the
+	// Target descriptor of the local image record named by the push ref,
+	// instead of pushing a separate manifest. The manifest pushed to the
+	// registry is not changed.
+	Inline bool
+
+	// Ref is the tag used for a non-inline cache manifest, e.g.
+	// "registry/repo:buildcache". Ignored when Inline is true.
+	Ref string
+}
+
+// WithRegistryCacheImport adds a CacheImport for the cache manifest at ref.
+func WithRegistryCacheImport(ref string) RemoteOpt {
+	return func(_ *Client, c *RemoteContext) error {
+		c.CacheImports = append(c.CacheImports, CacheImport{Ref: ref})
+		return nil
+	}
+}
+
+// WithInlineCacheExport adds a CacheExport that records the cache config as
+// an annotation on the local image record's Target descriptor (see
+// CacheExport.Inline).
+func WithInlineCacheExport() RemoteOpt {
+	return func(_ *Client, c *RemoteContext) error {
+		c.CacheExports = append(c.CacheExports, CacheExport{Inline: true})
+		return nil
+	}
+}
+
+// importCaches resolves and applies every configured CacheImport ahead of a
+// Fetch, so the dispatcher finds the layers already present in the content
+// store and skips their transfer.
+func (c *Client) importCaches(ctx context.Context, fetchCtx *RemoteContext) error {
+	for _, ci := range fetchCtx.CacheImports {
+		if err := c.importCache(ctx, fetchCtx, ci); err != nil {
+			return fmt.Errorf("importing cache %s: %w", ci.Ref, err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) importCache(ctx context.Context, fetchCtx *RemoteContext, ci CacheImport) error {
+	fetcher, err := fetchCtx.Resolver.Fetcher(ctx, ci.Ref)
+	if err != nil {
+		return err
+	}
+	_, desc, err := fetchCtx.Resolver.Resolve(ctx, ci.Ref)
+	if err != nil {
+		return err
+	}
+	rc, err := fetcher.Fetch(ctx, desc)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	var cfg CacheConfig
+	if err := json.NewDecoder(rc).Decode(&cfg); err != nil {
+		return fmt.Errorf("decoding cache config: %w", err)
+	}
+
+	store := c.ContentStore()
+	for _, layer := range cfg.Layers {
+		if err := importCacheLayer(ctx, store, fetcher, layer.Blob); err != nil {
+			return fmt.Errorf("importing cache layer %s: %w", layer.Blob.Digest, err)
+		}
+	}
+	return nil
+}
+
+// importCacheLayer fetches and commits a single cache layer blob into
+// store, skipping it if already present.
+func importCacheLayer(ctx context.Context, store content.Store, fetcher remotes.Fetcher, desc ocispec.Descriptor) error {
+	if _, err := store.Info(ctx, desc.Digest); err == nil {
+		return nil
+	}
+
+	body, err := fetcher.Fetch(ctx, desc)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	w, err := store.Writer(ctx, content.WithRef(desc.Digest.String()), content.WithDescriptor(desc))
+	if err != nil {
+		if errdefs.IsAlreadyExists(err) {
+			return nil
+		}
+		return err
+	}
+	defer w.Close()
+
+	if _, err := io.Copy(w, body); err != nil {
+		return err
+	}
+	if err := w.Commit(ctx, desc.Size, desc.Digest); err != nil && !errdefs.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// exportCaches runs every configured CacheExport for the image just pushed
+// to ref at desc.
+func (c *Client) exportCaches(ctx context.Context, pushCtx *RemoteContext, ref string, desc ocispec.Descriptor) error {
+	for _, ce := range pushCtx.CacheExports {
+		if err := c.exportCache(ctx, pushCtx, ce, ref, desc); err != nil {
+			return fmt.Errorf("exporting cache: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) exportCache(ctx context.Context, pushCtx *RemoteContext, ce CacheExport, ref string, desc ocispec.Descriptor) error {
+	cfg, err := c.buildCacheConfig(ctx, desc)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+
+	if ce.Inline {
+		img, err := c.ImageService().Get(ctx, ref)
+		if err != nil {
+			return fmt.Errorf("getting image %s for inline cache export: %w", ref, err)
+		}
+		img.Target.Annotations = mergeLabels(img.Target.Annotations, map[string]string{
+			cacheManifestsAnnotation: string(data),
+		})
+		// Annotations live under Target, not as a top-level Image field, so
+		// the whole descriptor is replaced rather than a bare "annotations"
+		// fieldpath (which images.Store.Update doesn't recognize).
+		_, err = c.ImageService().Update(ctx, img, "target")
+		return err
+	}
+
+	cacheDesc, err := writeContent(ctx, c.ContentStore(), cacheConfigMediaType, ce.Ref, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	return c.Push(ctx, ce.Ref, cacheDesc)
+}
+
+// buildCacheConfig walks the image manifest's layers in order and builds a
+// CacheConfig recording each layer's descriptor, its
+// containerd.io/uncompressed label, and the index of the layer before it as
+// ParentIndex. Diff IDs from the image config are not read and no chain IDs
+// are computed.
+func (c *Client) buildCacheConfig(ctx context.Context, desc ocispec.Descriptor) (*CacheConfig, error) {
+	store := c.ContentStore()
+	manifest, err := images.Manifest(ctx, store, desc, c.platform)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &CacheConfig{}
+	for i, layer := range manifest.Layers {
+		info, err := store.Info(ctx, layer.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("layer %s not present in content store: %w", layer.Digest, err)
+		}
+		cfg.Layers = append(cfg.Layers, CacheLayer{
+			Blob:        layer,
+			ParentIndex: i - 1,
+			Annotations: map[string]string{
+				"containerd.io/uncompressed": info.Labels["containerd.io/uncompressed"],
+			},
+		})
+	}
+	return cfg, nil
+}
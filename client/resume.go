@@ -0,0 +1,247 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/core/remotes"
+	"github.com/containerd/errdefs"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// defaultResumeStateTTL is how long an abandoned in-progress ingest is kept
+// before the reaper aborts it.
+const defaultResumeStateTTL = 24 * time.Hour
+
+// ResumeState is the resume checkpoint for a partially downloaded blob, read
+// back from the content store's own ingest tracking rather than any
+// bookkeeping of our own - the store already records how many leading bytes
+// of a ref's writer have been accepted, which is exactly what a resumed
+// fetch needs to compute its Range request.
+type ResumeState struct {
+	// Offset is the number of leading bytes of the blob already accepted
+	// by the in-progress writer.
+	Offset int64
+	// Total is the ingest's declared size, if known.
+	Total int64
+	// Updated is when the ingest was last written to, used by the reaper
+	// to expire abandoned resume state.
+	Updated time.Time
+}
+
+// WithResumeFrom configures a Fetch/Pull to resume layer downloads left
+// half-finished by a prior fetch, issuing a single Range request for the
+// bytes beyond whatever the content store's ingest tracking already has for
+// that blob's ref instead of re-downloading it from the start. prevRef only
+// switches resuming on; the ingest state is not looked up through it.
+func WithResumeFrom(prevRef string) RemoteOpt {
+	return func(_ *Client, c *RemoteContext) error {
+		c.ResumeFrom = prevRef
+		return nil
+	}
+}
+
+// resumeWrappingResolver wraps a remotes.Resolver so every Fetcher it hands
+// out resumes from the content store's existing ingest state instead of
+// re-downloading completed byte ranges.
+type resumeWrappingResolver struct {
+	remotes.Resolver
+	store content.Store
+}
+
+func (r *resumeWrappingResolver) Fetcher(ctx context.Context, ref string) (remotes.Fetcher, error) {
+	f, err := r.Resolver.Fetcher(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	return &resumableFetcher{Fetcher: f, store: r.store}, nil
+}
+
+// resumableFetcher wraps a remotes.Fetcher so that Fetch calls for content
+// already partially downloaded (per the content store's ingest status)
+// issue a Range request for only the missing suffix, verifying a
+// full (non-resumed) fetch against its digest as it streams.
+type resumableFetcher struct {
+	remotes.Fetcher
+	store content.Store
+}
+
+// rangeFetcher is implemented by remotes.Fetcher implementations (such as
+// the docker resolver's) that support byte-range requests.
+type rangeFetcher interface {
+	FetchRange(ctx context.Context, desc ocispec.Descriptor, offset, length int64) (io.ReadCloser, error)
+	// SupportsRangeRequests reports whether the remote has advertised
+	// "Accept-Ranges: bytes" for this host, so a resumed fetch can fail
+	// cleanly instead of silently re-downloading from the start.
+	SupportsRangeRequests(ctx context.Context) (bool, error)
+}
+
+func (f *resumableFetcher) Fetch(ctx context.Context, desc ocispec.Descriptor) (io.ReadCloser, error) {
+	ranged, ok := f.Fetcher.(rangeFetcher)
+	if !ok {
+		return f.Fetcher.Fetch(ctx, desc)
+	}
+
+	// The dispatcher keys the ingest writer it creates for this blob with
+	// remotes.MakeRefKey(ctx, desc) (e.g. "layer-sha256:..."), not the bare
+	// digest, so status lookups have to use the same key or they'll never
+	// find the in-progress ingest the dispatcher actually wrote.
+	ref := remotes.MakeRefKey(ctx, desc)
+	state, err := loadResumeState(ctx, f.store, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if state == nil || state.Offset <= 0 {
+		rc, err := f.Fetcher.Fetch(ctx, desc)
+		if err != nil {
+			return nil, err
+		}
+		return newVerifyingReader(rc, desc.Digest), nil
+	}
+	if state.Offset >= desc.Size {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+
+	supportsRanges, err := ranged.SupportsRangeRequests(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("checking range request support for %s: %w", desc.Digest, err)
+	}
+	if !supportsRanges {
+		return nil, fmt.Errorf("resuming fetch of %s: remote does not advertise range request support: %w", desc.Digest, errdefs.ErrUnavailable)
+	}
+
+	rc, err := ranged.FetchRange(ctx, desc, state.Offset, desc.Size-state.Offset)
+	if err != nil {
+		return nil, err
+	}
+	// The resumed suffix alone can't be checked against desc.Digest - that
+	// digest covers the whole blob, including the bytes the prior attempt
+	// already wrote - so the content store's Writer.Commit performs the
+	// authoritative check once the blob is fully reassembled. The common,
+	// non-resumed path above verifies the full stream as it downloads.
+	return rc, nil
+}
+
+// loadResumeState reads the content store's ingest status for ref, which
+// the store updates itself as its Writer accepts bytes, and returns nil if
+// there is no ingest in progress for ref.
+func loadResumeState(ctx context.Context, store content.Store, ref string) (*ResumeState, error) {
+	status, err := store.Status(ctx, ref)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if status.Offset <= 0 {
+		return nil, nil
+	}
+	return &ResumeState{Offset: status.Offset, Total: status.Total, Updated: status.UpdatedAt}, nil
+}
+
+// verifyingReader streams a Fetch's body through a digest.Verifier so a
+// corrupted or truncated full download is caught as soon as it's read
+// rather than only once the dispatcher commits it to the content store.
+type verifyingReader struct {
+	rc       io.ReadCloser
+	verifier digest.Verifier
+	checked  bool
+}
+
+func newVerifyingReader(rc io.ReadCloser, dgst digest.Digest) *verifyingReader {
+	return &verifyingReader{rc: rc, verifier: dgst.Verifier()}
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.rc.Read(p)
+	if n > 0 {
+		_, _ = v.verifier.Write(p[:n])
+	}
+	if err == io.EOF && !v.checked {
+		v.checked = true
+		if !v.verifier.Verified() {
+			return n, fmt.Errorf("fetched content failed digest verification: %w", errdefs.ErrFailedPrecondition)
+		}
+	}
+	return n, err
+}
+
+func (v *verifyingReader) Close() error {
+	return v.rc.Close()
+}
+
+// resumeReaper aborts in-progress ingests that haven't been written to in
+// over ttl, so an interrupted pull that's never retried doesn't pin partial
+// blobs in the content store forever. It is intended to be run
+// periodically by long-lived clients (e.g. a daemon's transfer service)
+// rather than per-call.
+type resumeReaper struct {
+	store content.Store
+	ttl   time.Duration
+
+	mu      sync.Mutex
+	lastRun time.Time
+}
+
+// newResumeReaper returns a reaper that aborts ingests idle for longer than
+// ttl. A zero ttl uses defaultResumeStateTTL.
+func newResumeReaper(store content.Store, ttl time.Duration) *resumeReaper {
+	if ttl <= 0 {
+		ttl = defaultResumeStateTTL
+	}
+	return &resumeReaper{store: store, ttl: ttl}
+}
+
+func (r *resumeReaper) reap(ctx context.Context) error {
+	r.mu.Lock()
+	r.lastRun = time.Now()
+	r.mu.Unlock()
+
+	statuses, err := r.store.ListStatuses(ctx)
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().Add(-r.ttl)
+	for _, status := range statuses {
+		if status.UpdatedAt.After(cutoff) {
+			continue
+		}
+		if err := r.store.Abort(ctx, status.Ref); err != nil && !errdefs.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReapResumeState aborts in-progress ingests in the content store that
+// haven't been written to in over ttl, so an interrupted pull that's never
+// retried doesn't pin partial blobs forever. A zero ttl uses
+// defaultResumeStateTTL. Callers that want this run periodically (rather
+// than once, e.g. from a daemon's own background loop) are responsible for
+// calling it on their own schedule.
+func (c *Client) ReapResumeState(ctx context.Context, ttl time.Duration) error {
+	return newResumeReaper(c.ContentStore(), ttl).reap(ctx)
+}
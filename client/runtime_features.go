@@ -0,0 +1,193 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/containerd/containerd/v2/core/containers"
+	"github.com/containerd/errdefs"
+	"github.com/opencontainers/runtime-spec/specs-go/features"
+)
+
+// RuntimeFeatureGate declares the features a caller requires before
+// creating a container or task, so submission fails fast with a clear
+// error instead of surfacing an opaque runc error later.
+type RuntimeFeatureGate struct {
+	IDMap           bool
+	RRO             bool
+	MountExtensions []string
+	Annotations     []string
+}
+
+// FeatureGate pre-declares the runtime features required by the container
+// or task being created. Submission fails with a structured error naming
+// the missing feature instead of an opaque runtime error.
+func FeatureGate(gate RuntimeFeatureGate) NewContainerOpts {
+	return func(ctx context.Context, client *Client, c *containers.Container) error {
+		rf, err := client.RuntimeFeatures(ctx, c.Runtime.Name, nil)
+		if err != nil {
+			return err
+		}
+		return rf.require(gate)
+	}
+}
+
+// FeatureGateTask pre-declares the runtime features required by the task
+// being created for a container running under runtimePath, the NewTask
+// counterpart to FeatureGate. A NewTaskOpts callback, unlike a
+// NewContainerOpts one, has no access to the owning container's runtime
+// name, so the caller passes the same runtimePath the container was
+// created with.
+func FeatureGateTask(runtimePath string, gate RuntimeFeatureGate) NewTaskOpts {
+	return func(ctx context.Context, client *Client, ti *TaskInfo) error {
+		rf, err := client.RuntimeFeatures(ctx, runtimePath, nil)
+		if err != nil {
+			return err
+		}
+		return rf.require(gate)
+	}
+}
+
+// RuntimeFeatures holds the parsed OCI runtime-spec Features document for a
+// runtime, exposing typed predicates in place of inspecting the raw
+// document at each call site.
+type RuntimeFeatures struct {
+	raw *features.Features
+}
+
+// RuntimeFeatures returns typed predicates over the Features document the
+// runtime plugin reports for runtimePath, so callers can check support for
+// idmapped mounts, recursive readonly mounts, mount options and annotations
+// before issuing calls that require them. There are no predicates for user
+// namespaces, NRI or the cgroup v2 freezer.
+func (c *Client) RuntimeFeatures(ctx context.Context, runtimePath string, runtimeOptions interface{}) (*RuntimeFeatures, error) {
+	cacheKey := runtimePath
+	if cacheKey == "" {
+		runtime, err := c.defaultRuntime(ctx)
+		if err != nil {
+			return nil, err
+		}
+		cacheKey = runtime
+	}
+	if cached, ok := c.runtimeFeaturesCache.Load(cacheKey); ok {
+		return cached.(*RuntimeFeatures), nil
+	}
+
+	info, err := c.RuntimeInfo(ctx, runtimePath, runtimeOptions)
+	if err != nil {
+		return nil, err
+	}
+	f, ok := info.Features.(*features.Features)
+	if !ok {
+		return nil, fmt.Errorf("runtime %s did not report a Features document: %w", cacheKey, errdefs.ErrNotImplemented)
+	}
+
+	rf := &RuntimeFeatures{raw: f}
+	c.runtimeFeaturesCache.Store(cacheKey, rf)
+	return rf, nil
+}
+
+// SupportsIDMap reports whether the runtime advertises support for
+// idmapped mounts.
+func (f *RuntimeFeatures) SupportsIDMap() bool {
+	if f.raw == nil || f.raw.Linux == nil || f.raw.Linux.MountExtensions == nil {
+		return false
+	}
+	idmap := f.raw.Linux.MountExtensions.IDMap
+	return idmap != nil && idmap.Enabled != nil && *idmap.Enabled
+}
+
+// SupportsRRO reports whether the runtime advertises support for recursive
+// readonly mounts.
+func (f *RuntimeFeatures) SupportsRRO() bool {
+	return stringInList(f.mountOptions(), "rro")
+}
+
+// SupportsMountExtensions reports whether the runtime advertises support
+// for every mount option in exts.
+func (f *RuntimeFeatures) SupportsMountExtensions(exts []string) bool {
+	opts := f.mountOptions()
+	for _, e := range exts {
+		if !stringInList(opts, e) {
+			return false
+		}
+	}
+	return true
+}
+
+// SupportsAnnotations reports whether the runtime's pattern allow-list
+// (Annotations in the Features document, a slice of glob patterns) admits
+// every annotation key in keys.
+func (f *RuntimeFeatures) SupportsAnnotations(keys []string) bool {
+	if f.raw == nil {
+		return false
+	}
+	for _, k := range keys {
+		if !annotationAllowed(f.raw.Annotations, k) {
+			return false
+		}
+	}
+	return true
+}
+
+// annotationAllowed reports whether key matches one of the glob patterns in
+// the runtime's Annotations allow-list.
+func annotationAllowed(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if ok, err := path.Match(pattern, key); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *RuntimeFeatures) mountOptions() []string {
+	if f.raw == nil {
+		return nil
+	}
+	return f.raw.MountOptions
+}
+
+func stringInList(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// require returns a structured error identifying the first feature in gate
+// that f does not support, or nil if every requirement is satisfied.
+func (f *RuntimeFeatures) require(gate RuntimeFeatureGate) error {
+	if gate.IDMap && !f.SupportsIDMap() {
+		return fmt.Errorf("runtime does not support idmapped mounts: %w", errdefs.ErrNotImplemented)
+	}
+	if gate.RRO && !f.SupportsRRO() {
+		return fmt.Errorf("runtime does not support recursive readonly mounts: %w", errdefs.ErrNotImplemented)
+	}
+	if len(gate.MountExtensions) > 0 && !f.SupportsMountExtensions(gate.MountExtensions) {
+		return fmt.Errorf("runtime does not support required mount extensions %v: %w", gate.MountExtensions, errdefs.ErrNotImplemented)
+	}
+	if len(gate.Annotations) > 0 && !f.SupportsAnnotations(gate.Annotations) {
+		return fmt.Errorf("runtime does not support required annotations %v: %w", gate.Annotations, errdefs.ErrNotImplemented)
+	}
+	return nil
+}
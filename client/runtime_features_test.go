@@ -0,0 +1,62 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package client
+
+import "testing"
+
+// TestRuntimeFeaturesCacheIsPerClient guards against the cache regressing to
+// a package-level map: two Clients caching Features for the same runtime
+// path must not see each other's entries.
+func TestRuntimeFeaturesCacheIsPerClient(t *testing.T) {
+	const runtimePath = "io.containerd.runc.v2"
+
+	c1 := &Client{}
+	c2 := &Client{}
+
+	rf1 := &RuntimeFeatures{}
+	c1.runtimeFeaturesCache.Store(runtimePath, rf1)
+
+	if _, ok := c2.runtimeFeaturesCache.Load(runtimePath); ok {
+		t.Fatal("second Client observed the first Client's cached RuntimeFeatures")
+	}
+
+	rf2 := &RuntimeFeatures{}
+	c2.runtimeFeaturesCache.Store(runtimePath, rf2)
+
+	got1, ok := c1.runtimeFeaturesCache.Load(runtimePath)
+	if !ok || got1.(*RuntimeFeatures) != rf1 {
+		t.Fatal("first Client's cache entry was overwritten by the second Client's Store")
+	}
+}
+
+func TestAnnotationAllowed(t *testing.T) {
+	patterns := []string{"org.example.*", "exact.key"}
+	cases := []struct {
+		key  string
+		want bool
+	}{
+		{"org.example.foo", true},
+		{"org.example.foo.bar", false},
+		{"exact.key", true},
+		{"unrelated", false},
+	}
+	for _, tc := range cases {
+		if got := annotationAllowed(patterns, tc.key); got != tc.want {
+			t.Errorf("annotationAllowed(%v, %q) = %v, want %v", patterns, tc.key, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/containerd/containerd/v2/core/remotes/docker"
+)
+
+// sessionIDMetadataKey is how the session ID rides in gRPC metadata so the
+// namespace interceptor chain forwards it to the daemon automatically,
+// without every call site threading it through by hand.
+const sessionIDMetadataKey = "containerd-session-id"
+
+// AuthAttachableName is the Attachable.Name() a Session's AuthAttachable
+// must return for WithSession to find it and resolve Fetch/Push credentials
+// through it.
+const AuthAttachableName = "auth.v1"
+
+// Attachable is a named capability a Session exposes to the daemon, such as
+// credential lookup or secret/SSH-agent forwarding. It mirrors BuildKit's
+// session manager model: the client registers attachables by name, and the
+// daemon calls back into whichever one it needs for the request at hand.
+type Attachable interface {
+	// Name identifies the attachable on the wire, e.g. "auth.v1",
+	// "secrets.v1", "sshforward.v1".
+	Name() string
+}
+
+// AuthAttachable supplies registry credentials for a given host from the
+// calling process, so no long-lived secrets need be held on disk.
+// WithSession wires an AuthAttachable registered under AuthAttachableName
+// into the RemoteContext's resolver, so Fetch and Push actually call back
+// into it rather than just carrying the session ID as inert metadata.
+type AuthAttachable interface {
+	Attachable
+	Credentials(ctx context.Context, host string) (username, secret string, err error)
+}
+
+// SecretsAttachable lets the daemon ask the client for a named secret's
+// value, for build-time secret forwarding. Nothing in this package calls
+// back into one yet - wiring it up is for the first consumer that does
+// build-time secret mounting, the same way AuthAttachable is wired into
+// WithSession's resolver.
+type SecretsAttachable interface {
+	Attachable
+	GetSecret(ctx context.Context, id string) ([]byte, error)
+}
+
+// SSHForwardAttachable lets the daemon request an SSH agent forwarding
+// socket for the named agent. As with SecretsAttachable, nothing in this
+// package calls back into one yet; it's registered for forward
+// compatibility with a future SSH-forwarding consumer.
+type SSHForwardAttachable interface {
+	Attachable
+	Dial(ctx context.Context, id string) (any, error)
+}
+
+// Session groups the attachables (credentials, secrets, SSH forwarding) a
+// client offers for the Fetch/Push calls made with WithSession, under an ID
+// that rides in the gRPC metadata of those calls. A Session opens no stream
+// to the daemon, so the daemon has no channel to call back into it; of its
+// attachables, only an AuthAttachable is used, client-side, by the resolver
+// WithSession builds.
+type Session struct {
+	id string
+
+	mu          sync.Mutex
+	attachables map[string]Attachable
+}
+
+// NewSession creates a session identified by id. If id is empty, a random
+// UUID is generated.
+func NewSession(id string) *Session {
+	if id == "" {
+		id = uuid.New().String()
+	}
+	return &Session{id: id, attachables: make(map[string]Attachable)}
+}
+
+// ID returns the session's identifier, the same value threaded through
+// gRPC metadata on every call made with WithSession.
+func (s *Session) ID() string {
+	return s.id
+}
+
+// Allow registers an attachable the daemon may call back into for the
+// lifetime of this session.
+func (s *Session) Allow(a Attachable) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attachables[a.Name()] = a
+}
+
+// Attachable looks up a previously registered attachable by name.
+func (s *Session) Attachable(name string) (Attachable, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.attachables[name]
+	return a, ok
+}
+
+// SessionRegistry tracks sessions registered against a Client so callback
+// requests from the daemon can be resolved back to the Session they belong
+// to, by the session ID riding in the request's gRPC metadata.
+type SessionRegistry struct {
+	mu   sync.Mutex
+	byID map[string]*Session
+}
+
+func (s *SessionRegistry) register(sess *Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.byID == nil {
+		s.byID = make(map[string]*Session)
+	}
+	s.byID[sess.ID()] = sess
+}
+
+func (s *SessionRegistry) get(id string) (*Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.byID[id]
+	return sess, ok
+}
+
+// FromContext resolves the Session registered for the session ID carried in
+// ctx's incoming gRPC metadata (the same key outgoingSessionContext stamps
+// on the client side), so a daemon-side attachable callback handler can
+// look up which Session to call back into for the request it's handling.
+// It returns false if ctx carries no session ID or the ID isn't registered.
+func (s *SessionRegistry) FromContext(ctx context.Context) (*Session, bool) {
+	id, err := sessionIDFromContext(ctx)
+	if err != nil || id == "" {
+		return nil, false
+	}
+	return s.get(id)
+}
+
+// SessionService returns the session registry used to resolve callback
+// requests from the daemon for sessions opened with WithSession.
+func (c *Client) SessionService() *SessionRegistry {
+	c.sessionsMu.Do(func() { c.sessions = &SessionRegistry{} })
+	return c.sessions
+}
+
+// WithSession registers sess with the Client's SessionService and sets the
+// call's SessionID, which Fetch and Push add to the gRPC metadata of their
+// requests. If sess has an AuthAttachable registered under
+// AuthAttachableName, the call's resolver authenticates by calling into it,
+// the same way WithResolverCredentials does for a CredentialStore. No other
+// attachable is consulted, and the daemon does not call back into sess.
+func WithSession(sess *Session) RemoteOpt {
+	return func(c *Client, rc *RemoteContext) error {
+		c.SessionService().register(sess)
+		rc.SessionID = sess.ID()
+		if a, ok := sess.Attachable(AuthAttachableName); ok {
+			if auth, ok := a.(AuthAttachable); ok {
+				rc.Resolver = docker.NewResolver(docker.ResolverOptions{
+					Authorizer: authorizerFromAuthAttachable(auth),
+				})
+			}
+		}
+		return nil
+	}
+}
+
+// authorizerFromAuthAttachable builds a docker.Authorizer that resolves
+// credentials by calling back into auth, mirroring
+// authorizerFromCredentialStore's translation of an empty username into
+// docker.WithAuthCreds' identity-token flow.
+func authorizerFromAuthAttachable(auth AuthAttachable) docker.Authorizer {
+	return docker.NewDockerAuthorizer(docker.WithAuthCreds(func(host string) (string, string, error) {
+		return auth.Credentials(context.Background(), host)
+	}))
+}
+
+// outgoingSessionContext stamps ctx with the session ID in gRPC metadata so
+// it rides along automatically through the existing namespace interceptor
+// chain, the same way the default namespace is attached.
+func outgoingSessionContext(ctx context.Context, sessionID string) context.Context {
+	if sessionID == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, sessionIDMetadataKey, sessionID)
+}
+
+// sessionIDFromContext extracts the session ID the daemon should use to
+// resolve a credential/secret/SSH-forward callback for the current
+// request.
+func sessionIDFromContext(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", nil
+	}
+	vals := md.Get(sessionIDMetadataKey)
+	if len(vals) == 0 {
+		return "", nil
+	}
+	if len(vals) > 1 {
+		return "", fmt.Errorf("multiple session IDs in request metadata")
+	}
+	return vals[0], nil
+}
@@ -0,0 +1,217 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/containerd/containerd/v2/plugins"
+	"github.com/containerd/errdefs"
+)
+
+// SnapshotterInfo is a structured description of a snapshotter plugin's
+// capabilities, mirroring how RuntimeInfo describes a runtime plugin so
+// callers can negotiate which API surface a given snapshotter implements
+// instead of assuming the newest shape is always available - the same
+// problem the CSI snapshot v1beta1->v1 migration solved by having clients
+// learn which API surface the driver implements.
+type SnapshotterInfo struct {
+	Name string
+
+	// APIVersions maps an API surface name to the version the
+	// snapshotter implements it at, e.g. "prepare-remote" -> "v1",
+	// "usage" -> "v2", "cleanup" -> "v1", "label-handlers" -> "v1".
+	APIVersions map[string]string
+
+	Capabilities []string
+	Platforms    []ocispec.Platform
+
+	// DefaultLabels is reserved for default snapshot labels the
+	// snapshotter plugin applies; the introspection response this is
+	// built from doesn't expose any today, so it is always nil.
+	DefaultLabels map[string]string
+
+	// Features holds the capability strings that aren't in
+	// "<surface>/<version>" form (those are split into APIVersions
+	// instead), e.g. "cleanup" on a snapshotter that supports the batch
+	// Cleanup RPC but doesn't version it.
+	Features []string
+}
+
+// hasAPIVersion reports whether info advertises support for name at
+// version or newer, comparing the "vN" version strings numerically so a
+// snapshotter advertising "v2" satisfies a requirement of "v1".
+func (info SnapshotterInfo) hasAPIVersion(name, version string) bool {
+	got, ok := info.APIVersions[name]
+	if !ok {
+		return false
+	}
+	gotN, gotOK := parseAPIVersion(got)
+	wantN, wantOK := parseAPIVersion(version)
+	if !gotOK || !wantOK {
+		return got == version
+	}
+	return gotN >= wantN
+}
+
+// parseAPIVersion parses the "vN" capability version strings snapshotters
+// report (e.g. "v1", "v2") into their numeric ordinal.
+func parseAPIVersion(version string) (int, bool) {
+	if len(version) < 2 || version[0] != 'v' {
+		return 0, false
+	}
+	n := 0
+	for _, r := range version[1:] {
+		if r < '0' || r > '9' {
+			return 0, false
+		}
+		n = n*10 + int(r-'0')
+	}
+	return n, true
+}
+
+// SnapshotterInfo returns a structured description of the named
+// snapshotter's capabilities and API versions, queried from the
+// introspection service the same way GetSnapshotterCapabilities is.
+func (c *Client) SnapshotterInfo(ctx context.Context, snapshotterName string) (SnapshotterInfo, error) {
+	filters := []string{fmt.Sprintf("type==%s, id==%s", plugins.SnapshotPlugin, snapshotterName)}
+	in := c.IntrospectionService()
+
+	resp, err := in.Plugins(ctx, filters...)
+	if err != nil {
+		return SnapshotterInfo{}, err
+	}
+	if len(resp.Plugins) <= 0 {
+		return SnapshotterInfo{}, fmt.Errorf("inspection service could not find snapshotter %s plugin", snapshotterName)
+	}
+
+	sn := resp.Plugins[0]
+	info := SnapshotterInfo{
+		Name:         snapshotterName,
+		Capabilities: sn.Capabilities,
+		Platforms:    toPlatforms(sn.Platforms),
+		APIVersions:  apiVersionsFromCapabilities(sn.Capabilities),
+		Features:     unversionedCapabilities(sn.Capabilities),
+	}
+	return info, nil
+}
+
+// apiVersionsFromCapabilities splits "<surface>/<version>" style capability
+// strings (e.g. "prepare-remote/v1") into the APIVersions map, leaving
+// plain capability names (e.g. "cleanup") out of it so older snapshotters
+// that only report flat capability names report an empty map, signaling
+// callers to fall back to legacy behavior.
+func apiVersionsFromCapabilities(capabilities []string) map[string]string {
+	versions := make(map[string]string)
+	for _, capability := range capabilities {
+		for i := len(capability) - 1; i >= 0; i-- {
+			if capability[i] == '/' {
+				versions[capability[:i]] = capability[i+1:]
+				break
+			}
+		}
+	}
+	return versions
+}
+
+// unversionedCapabilities returns the capability strings that aren't in
+// "<surface>/<version>" form, complementing apiVersionsFromCapabilities.
+func unversionedCapabilities(capabilities []string) []string {
+	var features []string
+	for _, capability := range capabilities {
+		hasVersion := false
+		for i := len(capability) - 1; i >= 0; i-- {
+			if capability[i] == '/' {
+				hasVersion = true
+				break
+			}
+		}
+		if !hasVersion {
+			features = append(features, capability)
+		}
+	}
+	return features
+}
+
+// SnapshotterSelector picks the best available snapshotter for an image
+// operation out of a set of candidates, preferring the first candidate
+// whose SnapshotterInfo satisfies every required API version.
+type SnapshotterSelector struct {
+	// Candidates is checked in order; the first snapshotter satisfying
+	// Required wins.
+	Candidates []string
+
+	// Required maps an API surface name to the minimum version it must
+	// be implemented at for a candidate to be selected.
+	Required map[string]string
+}
+
+// Select returns the first candidate snapshotter that implements every
+// surface in s.Required. If none does, it returns the error from the last
+// candidate whose SnapshotterInfo lookup failed, or, if every lookup
+// succeeded, an error listing all of s.Required.
+func (s SnapshotterSelector) Select(ctx context.Context, c *Client) (string, error) {
+	var lastErr error
+	for _, name := range s.Candidates {
+		info, err := c.SnapshotterInfo(ctx, name)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if s.satisfies(info) {
+			return name, nil
+		}
+	}
+	if lastErr != nil {
+		return "", lastErr
+	}
+	return "", fmt.Errorf("no candidate snapshotter satisfies required API versions %v", s.Required)
+}
+
+func (s SnapshotterSelector) satisfies(info SnapshotterInfo) bool {
+	for surface, version := range s.Required {
+		if !info.hasAPIVersion(surface, version) {
+			return false
+		}
+	}
+	return true
+}
+
+// CleanupSnapshots removes each of the given snapshotter keys with Remove.
+//
+// A snapshotter advertising "cleanup/v1" also exposes a batch Cleanup RPC,
+// but that RPC sweeps every unreferenced snapshot in the store rather than
+// removing a caller-specified set, so it cannot back this function without
+// silently removing more than the caller asked for; callers that want that
+// global sweep should drive the snapshotter's Cleanup RPC directly instead
+// of going through CleanupSnapshots.
+func (c *Client) CleanupSnapshots(ctx context.Context, snapshotterName string, keys []string) error {
+	sn, err := c.getSnapshotter(ctx, snapshotterName)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := sn.Remove(ctx, key); err != nil && !errdefs.IsNotFound(err) {
+			return fmt.Errorf("removing snapshot %s: %w", key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package client
+
+import "testing"
+
+func TestSnapshotterInfoHasAPIVersion(t *testing.T) {
+	info := SnapshotterInfo{APIVersions: map[string]string{"prepare-remote": "v2"}}
+
+	cases := []struct {
+		name    string
+		version string
+		want    bool
+	}{
+		{"prepare-remote", "v1", true},  // newer-than-required must satisfy a minimum
+		{"prepare-remote", "v2", true},  // exact match
+		{"prepare-remote", "v3", false}, // older than required fails
+		{"missing-surface", "v1", false},
+	}
+	for _, tc := range cases {
+		if got := info.hasAPIVersion(tc.name, tc.version); got != tc.want {
+			t.Errorf("hasAPIVersion(%q, %q) = %v, want %v", tc.name, tc.version, got, tc.want)
+		}
+	}
+}
+
+func TestSnapshotterSelectorSatisfiesMinimumVersion(t *testing.T) {
+	s := SnapshotterSelector{Required: map[string]string{"cleanup": "v1"}}
+	newer := SnapshotterInfo{APIVersions: map[string]string{"cleanup": "v2"}}
+	if !s.satisfies(newer) {
+		t.Fatal("candidate advertising a newer version than required was incorrectly rejected")
+	}
+	older := SnapshotterInfo{APIVersions: map[string]string{"cleanup": "v1"}}
+	if !s.satisfies(older) {
+		t.Fatal("candidate advertising exactly the required version was rejected")
+	}
+	tooOld := SnapshotterInfo{}
+	if s.satisfies(tooOld) {
+		t.Fatal("candidate missing the surface entirely was incorrectly accepted")
+	}
+}
+
+func TestParseAPIVersion(t *testing.T) {
+	cases := []struct {
+		version string
+		want    int
+		wantOK  bool
+	}{
+		{"v1", 1, true},
+		{"v2", 2, true},
+		{"v10", 10, true},
+		{"1", 0, false},
+		{"vx", 0, false},
+		{"", 0, false},
+	}
+	for _, tc := range cases {
+		n, ok := parseAPIVersion(tc.version)
+		if n != tc.want || ok != tc.wantOK {
+			t.Errorf("parseAPIVersion(%q) = (%d, %v), want (%d, %v)", tc.version, n, ok, tc.want, tc.wantOK)
+		}
+	}
+}
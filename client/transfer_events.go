@@ -0,0 +1,179 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/containerd/containerd/v2/core/transfer"
+	"github.com/google/uuid"
+	"github.com/opencontainers/go-digest"
+)
+
+// TransferEventKind identifies the kind of a TransferEvent emitted over the
+// channel returned by Client.Transfer. Kept as an open string type (rather than an enum) so new event kinds can
+// be added without breaking callers that only match on a subset.
+type TransferEventKind string
+
+const (
+	PullLayerStarted   TransferEventKind = "pull.layer.started"
+	PullLayerProgress  TransferEventKind = "pull.layer.progress"
+	PullLayerCommitted TransferEventKind = "pull.layer.committed"
+
+	UnpackStarted   TransferEventKind = "unpack.started"
+	UnpackProgress  TransferEventKind = "unpack.progress"
+	UnpackCompleted TransferEventKind = "unpack.completed"
+
+	PushManifestPushed TransferEventKind = "push.manifest.pushed"
+	ResolveFailed      TransferEventKind = "resolve.failed"
+)
+
+// TransferEvent is a strongly typed progress update from Client.Transfer,
+// replacing the free-form progress strings transfer.Transferrer previously
+// delivered through the stream creator.
+type TransferEvent struct {
+	Kind TransferEventKind
+
+	// CorrelationID disambiguates events from concurrent transfers on the
+	// same stream.
+	CorrelationID string
+
+	// Digest is set when the progress message names a valid digest.
+	// Offset/Total are set from a trailing "<offset>/<total>" field of the
+	// progress message, as sent with PullLayerProgress.
+	Digest digest.Digest
+	Offset int64
+	Total  int64
+
+	// Ref holds the ref named by a progress message that does not name a
+	// digest (or the raw message if it names neither). The ResolveFailed
+	// event Transfer emits when the transfer itself fails carries only Err;
+	// its Ref is empty.
+	Ref string
+
+	// Err is populated for ResolveFailed.
+	Err string
+}
+
+// Transfer runs a transfer.Transferrer request between src and dst and
+// returns a channel of strongly typed TransferEvents in place of the
+// free-form progress strings transfer.Opt's progress callback previously
+// delivered, tagged with a correlation ID so concurrent pulls/pushes driven
+// through the same Client can be told apart.
+func (c *Client) Transfer(ctx context.Context, src, dst interface{}, opts ...transfer.Opt) (<-chan TransferEvent, error) {
+	correlationID := uuid.New().String()
+	out := make(chan TransferEvent)
+
+	progressOpt := transfer.WithProgress(func(msg string) {
+		sendTransferEvent(ctx, out, parseLegacyProgress(correlationID, msg))
+	})
+
+	go func() {
+		defer close(out)
+		err := c.TransferService().Transfer(ctx, src, dst, append(opts, progressOpt)...)
+		if err != nil {
+			sendTransferEvent(ctx, out, TransferEvent{Kind: ResolveFailed, CorrelationID: correlationID, Err: err.Error()})
+		}
+	}()
+
+	return out, nil
+}
+
+// sendTransferEvent sends e on out, or gives up if ctx is canceled before
+// the caller is ready to receive, so a caller that stops draining the
+// channel after canceling ctx doesn't block the transfer goroutine forever.
+func sendTransferEvent(ctx context.Context, out chan<- TransferEvent, e TransferEvent) bool {
+	select {
+	case out <- e:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// parseLegacyProgress maps a free-form progress string from the existing
+// stream-creator protocol onto a TransferEvent. The string format is
+// "<event> <ref-or-digest>[ <offset>/<total>]"; anything that doesn't
+// parse is passed through as PullLayerStarted with Ref set to the raw
+// message so no information is silently dropped.
+func parseLegacyProgress(correlationID, msg string) TransferEvent {
+	fields := strings.Fields(msg)
+	if len(fields) == 0 {
+		return TransferEvent{Kind: PullLayerStarted, CorrelationID: correlationID}
+	}
+
+	event := TransferEvent{CorrelationID: correlationID, Ref: msg}
+	switch fields[0] {
+	case "downloading":
+		event.Kind = PullLayerProgress
+	case "done":
+		event.Kind = PullLayerCommitted
+	case "extracting":
+		event.Kind = UnpackProgress
+	case "unpacked":
+		event.Kind = UnpackCompleted
+	case "pushed":
+		event.Kind = PushManifestPushed
+	default:
+		event.Kind = PullLayerStarted
+	}
+
+	if len(fields) > 1 {
+		if d, err := digest.Parse(fields[1]); err == nil {
+			event.Digest = d
+			event.Ref = ""
+		} else {
+			event.Ref = fields[1]
+		}
+	}
+	if len(fields) > 2 {
+		if off, total, ok := strings.Cut(fields[2], "/"); ok {
+			event.Offset, _ = strconv.ParseInt(off, 10, 64)
+			event.Total, _ = strconv.ParseInt(total, 10, 64)
+		}
+	}
+	return event
+}
+
+// transferEventStringAdapter renders a TransferEvent stream as the legacy
+// free-form progress strings, for callers that have not yet moved off the
+// string-based progress API.
+func transferEventStringAdapter(events <-chan TransferEvent) <-chan string {
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		for e := range events {
+			switch e.Kind {
+			case PullLayerProgress:
+				out <- "downloading " + e.Digest.String()
+			case PullLayerCommitted:
+				out <- "done " + e.Digest.String()
+			case UnpackProgress:
+				out <- "extracting " + e.Digest.String()
+			case UnpackCompleted:
+				out <- "unpacked " + e.Digest.String()
+			case ResolveFailed:
+				out <- "failed " + e.Ref + ": " + e.Err
+			default:
+				out <- string(e.Kind)
+			}
+		}
+	}()
+	return out
+}
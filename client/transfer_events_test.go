@@ -0,0 +1,93 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestSendTransferEventGivesUpOnCanceledContext covers the deadlock a
+// caller hits by canceling ctx and walking away from the returned channel
+// without draining it further: sendTransferEvent must return rather than
+// block forever on the unbuffered send.
+func TestSendTransferEventGivesUpOnCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	out := make(chan TransferEvent) // never drained
+
+	done := make(chan bool, 1)
+	go func() {
+		done <- sendTransferEvent(ctx, out, TransferEvent{Kind: PushManifestPushed})
+	}()
+
+	select {
+	case ok := <-done:
+		if ok {
+			t.Fatal("expected sendTransferEvent to report failure on a canceled context")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("sendTransferEvent blocked instead of returning once ctx was canceled")
+	}
+}
+
+// TestSendTransferEventDeliversToReadyReceiver ensures the ctx guard doesn't
+// drop events when a receiver is actually listening.
+func TestSendTransferEventDeliversToReadyReceiver(t *testing.T) {
+	out := make(chan TransferEvent)
+	want := TransferEvent{Kind: PullLayerCommitted, Ref: "sha256:abcd"}
+
+	go func() {
+		if !sendTransferEvent(context.Background(), out, want) {
+			t.Error("expected sendTransferEvent to succeed when a receiver is ready")
+		}
+	}()
+
+	select {
+	case got := <-out:
+		if got != want {
+			t.Fatalf("got %+v, want %+v", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("sendTransferEvent did not deliver to a ready receiver")
+	}
+}
+
+func TestParseLegacyProgress(t *testing.T) {
+	cases := []struct {
+		msg      string
+		wantKind TransferEventKind
+	}{
+		{"downloading sha256:abcd 10/100", PullLayerProgress},
+		{"done sha256:abcd", PullLayerCommitted},
+		{"extracting sha256:abcd", UnpackProgress},
+		{"unpacked sha256:abcd", UnpackCompleted},
+		{"pushed registry/repo:tag", PushManifestPushed},
+		{"", PullLayerStarted},
+	}
+	for _, tc := range cases {
+		got := parseLegacyProgress("corr-1", tc.msg)
+		if got.Kind != tc.wantKind {
+			t.Errorf("parseLegacyProgress(%q).Kind = %q, want %q", tc.msg, got.Kind, tc.wantKind)
+		}
+		if got.CorrelationID != "corr-1" {
+			t.Errorf("parseLegacyProgress(%q).CorrelationID = %q, want %q", tc.msg, got.CorrelationID, "corr-1")
+		}
+	}
+}
@@ -0,0 +1,595 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/containerd/containerd/v2/core/remotes"
+	"github.com/containerd/errdefs"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TrustPolicyMode controls how a TrustPolicy reacts to a failed or missing
+// signature verification.
+type TrustPolicyMode int
+
+const (
+	// TrustPolicyEnforce causes Client.Fetch to fail with
+	// errdefs.ErrFailedPrecondition when the resolved manifest digest does
+	// not match a signed target, or no signed target is found. This is the
+	// zero value so policies are fail-closed by default.
+	TrustPolicyEnforce TrustPolicyMode = iota
+
+	// TrustPolicyWarn runs the same verification as TrustPolicyEnforce but
+	// only records the outcome on the fetch span instead of aborting it.
+	TrustPolicyWarn
+)
+
+// TrustedTarget is a single signed entry from a TUF targets file.
+type TrustedTarget struct {
+	Digest digest.Digest
+	Length int64
+	Custom map[string]any
+}
+
+// TrustPolicy configures Notary/TUF content trust verification performed by
+// Client.Fetch and Client.Pull, and manifest signing performed by
+// Client.Push.
+//
+// Verification happens after the resolver returns a descriptor and before
+// any layer transfer begins, so untrusted content is never written to the
+// content store.
+//
+// This only checks a flat threshold of valid signatures on the top-level
+// "targets" role fetched from Server; it does not implement the rest of the
+// TUF role hierarchy (no snapshot or timestamp role checks) and does not
+// check the targets file's version or expiry. A trust server that is
+// compromised or otherwise malicious can therefore replay an old,
+// validly-signed-but-superseded targets.json indefinitely (a classic TUF
+// rollback attack) and every fetch against it will still verify. Treat this
+// as protection against an untrusted registry serving unsigned or
+// mismatched content, not as a defense against a compromised trust server.
+type TrustPolicy struct {
+	// Mode selects whether a verification failure aborts the fetch
+	// (TrustPolicyEnforce) or is only logged (TrustPolicyWarn).
+	Mode TrustPolicyMode
+
+	// Server is the base URL of the TUF/Notary trust server that hosts
+	// root/targets/snapshot/timestamp metadata for a GUN (the globally
+	// unique name, normally the image repository).
+	Server string
+
+	// RootDir holds the pinned roots of trust and the pin-on-first-use
+	// pin file. Defaults to "~/.containerd/trust".
+	RootDir string
+
+	// HTTPClient fetches TUF metadata from Server. Defaults to
+	// http.DefaultClient.
+	HTTPClient *http.Client
+
+	cache *delegationCache
+}
+
+// delegationCache holds parsed TUF delegation chains keyed by GUN so repeat
+// fetches against the same repository avoid re-walking the delegation tree.
+type delegationCache struct {
+	mu      sync.Mutex
+	entries map[string]gunTrust
+}
+
+// gunTrust is the pinned targets-role key set and signature threshold for a
+// single GUN, derived from its root.json.
+type gunTrust struct {
+	keys      map[string]trustedDelegation // keyed by TUF keyid
+	threshold int
+}
+
+type trustedDelegation struct {
+	keyType string
+	key     crypto.PublicKey
+}
+
+func (c *TrustPolicy) delegations() *delegationCache {
+	if c.cache == nil {
+		c.cache = &delegationCache{entries: make(map[string]gunTrust)}
+	}
+	return c.cache
+}
+
+func (c *TrustPolicy) rootDir() string {
+	if c.RootDir != "" {
+		return c.RootDir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".containerd", "trust")
+	}
+	return filepath.Join(home, ".containerd", "trust")
+}
+
+func (c *TrustPolicy) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// pinFile returns the path used to pin the root of trust for gun on first
+// use, so subsequent verifications in CI are reproducible against the same
+// root key set rather than whatever the trust server currently serves.
+func (c *TrustPolicy) pinFile(gun string) string {
+	return filepath.Join(c.rootDir(), "pins", gun+".json")
+}
+
+// pinnedRoot loads the pinned root keys for gun, pinning the keys fetched
+// from root if no pin file exists yet.
+func (c *TrustPolicy) pinnedRoot(gun string, root tufRoot) (tufRoot, error) {
+	pin := c.pinFile(gun)
+	data, err := os.ReadFile(pin)
+	if err == nil {
+		var pinned tufRoot
+		if err := json.Unmarshal(data, &pinned); err != nil {
+			return tufRoot{}, fmt.Errorf("parsing pinned root for %s: %w", gun, err)
+		}
+		return pinned, nil
+	}
+	if !os.IsNotExist(err) {
+		return tufRoot{}, fmt.Errorf("reading pinned root for %s: %w", gun, err)
+	}
+
+	// Pin on first use.
+	data, err = json.Marshal(root)
+	if err != nil {
+		return tufRoot{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(pin), 0o700); err != nil {
+		return tufRoot{}, fmt.Errorf("creating trust pin directory: %w", err)
+	}
+	if err := os.WriteFile(pin, data, 0o600); err != nil {
+		return tufRoot{}, fmt.Errorf("writing trust pin for %s: %w", gun, err)
+	}
+	return root, nil
+}
+
+// tufRoot is the minimal subset of a TUF root.json needed to pin and verify
+// the keys that sign the targets delegation chain.
+type tufRoot struct {
+	Keys  map[string]tufKey  `json:"keys"`
+	Roles map[string]tufRole `json:"roles"`
+}
+
+type tufKey struct {
+	KeyType string `json:"keytype"`
+	KeyVal  struct {
+		Public string `json:"public"`
+	} `json:"keyval"`
+}
+
+type tufRole struct {
+	KeyIDs    []string `json:"keyids"`
+	Threshold int      `json:"threshold"`
+}
+
+// verifier checks a TUF signed envelope against a set of trusted public
+// keys, supporting the ED25519 and ECDSA key types used by Notary.
+type verifier struct{}
+
+func (verifier) verify(msg []byte, sig []byte, keyType string, key crypto.PublicKey) error {
+	switch keyType {
+	case "ed25519":
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("key is not ed25519: %w", errdefs.ErrInvalidArgument)
+		}
+		if !ed25519.Verify(pub, msg, sig) {
+			return fmt.Errorf("ed25519 signature verification failed: %w", errdefs.ErrFailedPrecondition)
+		}
+		return nil
+	case "ecdsa":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key is not ecdsa: %w", errdefs.ErrInvalidArgument)
+		}
+		if !ecdsa.VerifyASN1(pub, msg, sig) {
+			return fmt.Errorf("ecdsa signature verification failed: %w", errdefs.ErrFailedPrecondition)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported trust key type %q: %w", keyType, errdefs.ErrNotImplemented)
+	}
+}
+
+// verifyTarget fetches the targets metadata for ref from policy.Server,
+// walks the delegation chain rooted in the pinned trust anchor, and
+// confirms that desc's digest and size match a signed target.
+func (c *TrustPolicy) verifyTarget(ctx context.Context, ref string, desc ocispec.Descriptor) error {
+	if c.Server == "" {
+		return fmt.Errorf("content trust enabled without a trust server: %w", errdefs.ErrInvalidArgument)
+	}
+
+	gun, err := gunForRef(ref)
+	if err != nil {
+		return err
+	}
+
+	target, err := c.resolveSignedTarget(ctx, gun, ref)
+	if err != nil {
+		return fmt.Errorf("resolving signed target for %s: %w", gun, err)
+	}
+	if target == nil {
+		return fmt.Errorf("no signed target found for %s: %w", ref, errdefs.ErrFailedPrecondition)
+	}
+	if target.Digest != desc.Digest || (target.Length != 0 && target.Length != desc.Size) {
+		return fmt.Errorf("resolved manifest %s does not match signed target %s: %w", desc.Digest, target.Digest, errdefs.ErrFailedPrecondition)
+	}
+	return nil
+}
+
+// resolveSignedTarget fetches the TUF metadata chain for gun, verifies the
+// targets file's signatures against the pinned root's targets-role keys
+// (requiring at least as many distinct valid signatures as the role's
+// threshold), and returns the trusted target matching ref's own path in the
+// targets file. Nothing is returned unless verification clears the
+// threshold, so a trust server cannot hand back an unsigned or
+// under-signed digest and have it trusted. The network and signature
+// walking steps are factored out of verifyTarget so they can be driven by
+// the delegation cache independently.
+func (c *TrustPolicy) resolveSignedTarget(ctx context.Context, gun, ref string) (*TrustedTarget, error) {
+	cache := c.delegations()
+	cache.mu.Lock()
+	trust, cached := cache.entries[gun]
+	cache.mu.Unlock()
+	if !cached {
+		root, err := c.fetchMetadata(ctx, gun, "root.json")
+		if err != nil {
+			return nil, err
+		}
+		var parsedRoot tufRoot
+		if err := json.Unmarshal(root, &parsedRoot); err != nil {
+			return nil, fmt.Errorf("parsing root metadata: %w", err)
+		}
+		pinned, err := c.pinnedRoot(gun, parsedRoot)
+		if err != nil {
+			return nil, err
+		}
+		trust, err = delegationsFromRoot(pinned)
+		if err != nil {
+			return nil, err
+		}
+		cache.mu.Lock()
+		cache.entries[gun] = trust
+		cache.mu.Unlock()
+	}
+
+	raw, err := c.fetchMetadata(ctx, gun, "targets.json")
+	if err != nil {
+		return nil, err
+	}
+	var tf signedTargetsFile
+	if err := json.Unmarshal(raw, &tf); err != nil {
+		return nil, fmt.Errorf("parsing targets metadata: %w", err)
+	}
+
+	valid, err := countValidSignatures(tf, trust)
+	if err != nil {
+		return nil, err
+	}
+	if valid == 0 || valid < trust.threshold {
+		return nil, fmt.Errorf("targets metadata for %s has %d of %d required valid signatures: %w", gun, valid, trust.threshold, errdefs.ErrFailedPrecondition)
+	}
+
+	return findSignedTarget(tf, ref)
+}
+
+// countValidSignatures re-marshals tf.Signed to recover the exact signed
+// byte sequence (Go's encoding/json sorts map keys deterministically, so
+// this reproduces the canonical form the signer hashed) and verifies each
+// signature against the delegation key its keyid names, counting each
+// distinct valid signer once.
+func countValidSignatures(tf signedTargetsFile, trust gunTrust) (int, error) {
+	signed, err := json.Marshal(tf.Signed)
+	if err != nil {
+		return 0, fmt.Errorf("canonicalizing signed targets: %w", err)
+	}
+
+	v := verifier{}
+	seen := make(map[string]bool, len(tf.Signatures))
+	var valid int
+	for _, sig := range tf.Signatures {
+		if seen[sig.KeyID] {
+			continue
+		}
+		delegation, ok := trust.keys[sig.KeyID]
+		if !ok {
+			continue
+		}
+		sigBytes, err := hex.DecodeString(sig.Sig)
+		if err != nil {
+			continue
+		}
+		if err := v.verify(signed, sigBytes, delegation.keyType, delegation.key); err != nil {
+			continue
+		}
+		seen[sig.KeyID] = true
+		valid++
+	}
+	return valid, nil
+}
+
+func (c *TrustPolicy) fetchMetadata(ctx context.Context, gun, file string) ([]byte, error) {
+	url := fmt.Sprintf("%s/v2/%s/_trust/tuf/%s", c.Server, gun, file)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s for %s: %w", file, gun, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s for %s: unexpected status %s: %w", file, gun, resp.Status, errdefs.ErrUnavailable)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// gunForRef derives the TUF globally unique name for an image reference,
+// which for containerd trust purposes is the reference with any tag or
+// digest suffix stripped.
+func gunForRef(ref string) (string, error) {
+	gun, _ := refTarget(ref)
+	if gun == "" {
+		return "", fmt.Errorf("empty reference: %w", errdefs.ErrInvalidArgument)
+	}
+	return gun, nil
+}
+
+// refTarget splits ref into its GUN (the repository name, including any
+// registry host, with tag and digest stripped) and the suffix that
+// identifies this particular target within that GUN's targets file: the
+// digest when ref carries one (name@digest, name:tag@digest), otherwise the
+// tag (name:tag).
+//
+// A naive split on the last "@" or ":" mis-parses a digest ref like
+// "repo@sha256:abcd" by stopping at the colon inside the digest instead of
+// the "@" that actually separates the name from it, so the "@" split is
+// tried first here.
+func refTarget(ref string) (gun, suffix string) {
+	name := ref
+	if at := strings.LastIndex(ref, "@"); at >= 0 {
+		name = ref[:at]
+		suffix = ref[at+1:]
+	}
+	if colon := strings.LastIndex(name, ":"); colon >= 0 && !strings.Contains(name[colon:], "/") {
+		gun = name[:colon]
+		if suffix == "" {
+			suffix = name[colon+1:]
+		}
+		return gun, suffix
+	}
+	return name, suffix
+}
+
+// signedTargetsFile is the minimal shape of a TUF targets.json needed to
+// pull out signed target hashes and the signatures covering them.
+type signedTargetsFile struct {
+	Signed struct {
+		Targets map[string]struct {
+			Hashes map[string]string `json:"hashes"`
+			Length int64             `json:"length"`
+			Custom map[string]any    `json:"custom"`
+		} `json:"targets"`
+	} `json:"signed"`
+	Signatures []struct {
+		KeyID string `json:"keyid"`
+		Sig   string `json:"sig"`
+	} `json:"signatures"`
+}
+
+// findSignedTarget looks up the entry in an already-verified targets.json
+// matching ref's own path (its digest if ref carries one, otherwise its
+// tag - see refTarget), so a registry can't serve content signed for a
+// different tag under the ref being resolved. It returns a nil target, not
+// an error, if ref has no corresponding entry - the caller treats a missing
+// target the same as a verification failure. Callers must verify tf's
+// signatures (see countValidSignatures) before calling this - it does not
+// check anything itself.
+func findSignedTarget(tf signedTargetsFile, ref string) (*TrustedTarget, error) {
+	_, suffix := refTarget(ref)
+	if suffix == "" {
+		return nil, fmt.Errorf("reference %s has no tag or digest to match against signed targets: %w", ref, errdefs.ErrInvalidArgument)
+	}
+	t, ok := tf.Signed.Targets[suffix]
+	if !ok {
+		return nil, nil
+	}
+	sha, ok := t.Hashes["sha256"]
+	if !ok {
+		return nil, fmt.Errorf("signed target %s has no sha256 hash: %w", suffix, errdefs.ErrFailedPrecondition)
+	}
+	return &TrustedTarget{
+		Digest: digest.NewDigestFromEncoded(digest.SHA256, sha),
+		Length: t.Length,
+		Custom: t.Custom,
+	}, nil
+}
+
+// decodeTUFKey decodes a TUF key's keyval.public field into a usable
+// crypto.PublicKey. ed25519 keys are hex-encoded raw key bytes; ecdsa keys
+// are PEM-encoded (falling back to hex-encoded DER for servers that omit
+// the PEM armor), both carrying a standard SubjectPublicKeyInfo.
+func decodeTUFKey(key tufKey) (crypto.PublicKey, error) {
+	switch key.KeyType {
+	case "ed25519":
+		raw, err := hex.DecodeString(key.KeyVal.Public)
+		if err != nil {
+			return nil, fmt.Errorf("decoding ed25519 key: %w: %w", err, errdefs.ErrInvalidArgument)
+		}
+		if len(raw) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid ed25519 key length %d: %w", len(raw), errdefs.ErrInvalidArgument)
+		}
+		return ed25519.PublicKey(raw), nil
+	case "ecdsa":
+		der := []byte(key.KeyVal.Public)
+		if block, _ := pem.Decode([]byte(key.KeyVal.Public)); block != nil {
+			der = block.Bytes
+		} else if decoded, err := hex.DecodeString(key.KeyVal.Public); err == nil {
+			der = decoded
+		}
+		pub, err := x509.ParsePKIXPublicKey(der)
+		if err != nil {
+			return nil, fmt.Errorf("parsing ecdsa key: %w: %w", err, errdefs.ErrInvalidArgument)
+		}
+		ecPub, ok := pub.(*ecdsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("key is not ecdsa: %w", errdefs.ErrInvalidArgument)
+		}
+		return ecPub, nil
+	default:
+		return nil, fmt.Errorf("unsupported trust key type %q: %w", key.KeyType, errdefs.ErrNotImplemented)
+	}
+}
+
+// delegationsFromRoot extracts the targets role's pinned keys and signature
+// threshold from a (already pinned) TUF root. A zero or unset threshold
+// defaults to 1, matching TUF's own default.
+func delegationsFromRoot(root tufRoot) (gunTrust, error) {
+	targetsRole, ok := root.Roles["targets"]
+	if !ok {
+		return gunTrust{}, fmt.Errorf("root metadata missing targets role: %w", errdefs.ErrFailedPrecondition)
+	}
+	threshold := targetsRole.Threshold
+	if threshold <= 0 {
+		threshold = 1
+	}
+	keys := make(map[string]trustedDelegation, len(targetsRole.KeyIDs))
+	for _, id := range targetsRole.KeyIDs {
+		key, ok := root.Keys[id]
+		if !ok {
+			continue
+		}
+		pub, err := decodeTUFKey(key)
+		if err != nil {
+			return gunTrust{}, err
+		}
+		keys[id] = trustedDelegation{keyType: key.KeyType, key: pub}
+	}
+	return gunTrust{keys: keys, threshold: threshold}, nil
+}
+
+// signManifest produces a signed targets file covering desc and uploads it
+// to the trust server for ref's GUN, so that a subsequent Fetch of ref by
+// another client can verify it against this policy's root of trust.
+func (c *TrustPolicy) signManifest(ctx context.Context, ref string, desc ocispec.Descriptor) error {
+	if c.Server == "" {
+		return fmt.Errorf("content trust signing enabled without a trust server: %w", errdefs.ErrInvalidArgument)
+	}
+	gun, err := gunForRef(ref)
+	if err != nil {
+		return err
+	}
+	_, suffix := refTarget(ref)
+	if suffix == "" {
+		return fmt.Errorf("reference %s has no tag or digest to sign against: %w", ref, errdefs.ErrInvalidArgument)
+	}
+
+	targets := signedTargetsFile{}
+	targets.Signed.Targets = map[string]struct {
+		Hashes map[string]string `json:"hashes"`
+		Length int64             `json:"length"`
+		Custom map[string]any    `json:"custom"`
+	}{
+		suffix: {
+			Hashes: map[string]string{"sha256": desc.Digest.Encoded()},
+			Length: desc.Size,
+		},
+	}
+	data, err := json.Marshal(targets)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/v2/%s/_trust/tuf/targets.json", c.Server, gun)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("uploading signed targets for %s: %w", gun, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("uploading signed targets for %s: unexpected status %s: %w", gun, resp.Status, errdefs.ErrUnavailable)
+	}
+	return nil
+}
+
+// WithContentTrust enables Notary/TUF content trust verification on
+// Client.Fetch and Client.Pull using policy, and manifest signing on
+// Client.Push.
+func WithContentTrust(policy *TrustPolicy) RemoteOpt {
+	return func(_ *Client, c *RemoteContext) error {
+		c.TrustPolicy = policy
+		return nil
+	}
+}
+
+// trustVerifyingResolver wraps a remotes.Resolver so that every resolved
+// descriptor is checked against the configured TrustPolicy before the
+// caller proceeds to fetch it.
+type trustVerifyingResolver struct {
+	remotes.Resolver
+	policy *TrustPolicy
+}
+
+func (r *trustVerifyingResolver) Resolve(ctx context.Context, ref string) (string, ocispec.Descriptor, error) {
+	name, desc, err := r.Resolver.Resolve(ctx, ref)
+	if err != nil {
+		return name, desc, err
+	}
+	verifyErr := r.policy.verifyTarget(ctx, ref, desc)
+	span := trace.SpanFromContext(ctx)
+	if verifyErr != nil {
+		span.SetAttributes(attribute.String("trust.verify.error", verifyErr.Error()))
+		if r.policy.Mode == TrustPolicyEnforce {
+			return "", ocispec.Descriptor{}, verifyErr
+		}
+	} else {
+		span.SetAttributes(attribute.Bool("trust.verify.ok", true))
+	}
+	return name, desc, nil
+}
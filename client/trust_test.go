@@ -0,0 +1,221 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containerd/errdefs"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// newTrustKey generates an ed25519 key pair and wraps its public half in the
+// tufKey shape delegationsFromRoot expects to find in a root.json.
+func newTrustKey(t *testing.T) (ed25519.PrivateKey, tufKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating trust key: %v", err)
+	}
+	key := tufKey{KeyType: "ed25519"}
+	key.KeyVal.Public = hex.EncodeToString(pub)
+	return priv, key
+}
+
+// signTargets builds a signedTargetsFile covering targets and signs it with
+// each of signers, re-marshaling Signed the same way countValidSignatures
+// does so the recovered bytes match what was actually hashed.
+func signTargets(t *testing.T, targets map[string]digest.Digest, signers map[string]ed25519.PrivateKey) signedTargetsFile {
+	t.Helper()
+	var tf signedTargetsFile
+	tf.Signed.Targets = make(map[string]struct {
+		Hashes map[string]string `json:"hashes"`
+		Length int64             `json:"length"`
+		Custom map[string]any    `json:"custom"`
+	}, len(targets))
+	for suffix, dgst := range targets {
+		tf.Signed.Targets[suffix] = struct {
+			Hashes map[string]string `json:"hashes"`
+			Length int64             `json:"length"`
+			Custom map[string]any    `json:"custom"`
+		}{
+			Hashes: map[string]string{"sha256": dgst.Encoded()},
+			Length: 100,
+		}
+	}
+
+	signed, err := json.Marshal(tf.Signed)
+	if err != nil {
+		t.Fatalf("marshaling signed targets: %v", err)
+	}
+	for keyID, priv := range signers {
+		sig := ed25519.Sign(priv, signed)
+		tf.Signatures = append(tf.Signatures, struct {
+			KeyID string `json:"keyid"`
+			Sig   string `json:"sig"`
+		}{KeyID: keyID, Sig: hex.EncodeToString(sig)})
+	}
+	return tf
+}
+
+// trustServer serves root.json/targets.json for a single gun, with the
+// ability to mutate the targets response after it's been signed to
+// simulate a registry tampering with already-signed metadata.
+func trustServer(t *testing.T, gun string, root tufRoot, tf signedTargetsFile, tamper func(*signedTargetsFile)) *httptest.Server {
+	t.Helper()
+	rootBytes, err := json.Marshal(root)
+	if err != nil {
+		t.Fatalf("marshaling root: %v", err)
+	}
+	if tamper != nil {
+		tamper(&tf)
+	}
+	targetsBytes, err := json.Marshal(tf)
+	if err != nil {
+		t.Fatalf("marshaling targets: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/"+gun+"/_trust/tuf/root.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(rootBytes)
+	})
+	mux.HandleFunc("/v2/"+gun+"/_trust/tuf/targets.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(targetsBytes)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestResolveSignedTargetMatchesRequestedRef(t *testing.T) {
+	const gun = "library/redis"
+	priv, key := newTrustKey(t)
+	root := tufRoot{
+		Keys:  map[string]tufKey{"key1": key},
+		Roles: map[string]tufRole{"targets": {KeyIDs: []string{"key1"}, Threshold: 1}},
+	}
+
+	v1Digest := digest.FromString("v1 content")
+	v2Digest := digest.FromString("v2 content")
+	tf := signTargets(t, map[string]digest.Digest{
+		"v1": v1Digest,
+		"v2": v2Digest,
+	}, map[string]ed25519.PrivateKey{"key1": priv})
+
+	srv := trustServer(t, gun, root, tf, nil)
+	policy := &TrustPolicy{Server: srv.URL, RootDir: t.TempDir()}
+
+	target, err := policy.resolveSignedTarget(context.Background(), gun, gun+":v1")
+	if err != nil {
+		t.Fatalf("resolveSignedTarget: %v", err)
+	}
+	if target == nil {
+		t.Fatal("expected a signed target for v1, got nil")
+	}
+	if target.Digest != v1Digest {
+		t.Fatalf("resolveSignedTarget matched %s, want the v1 entry's digest %s", target.Digest, v1Digest)
+	}
+
+	// A registry serving v2's (legitimately signed) content under the v1
+	// ref must not verify - this is the reference-confusion case.
+	v2Desc := ocispec.Descriptor{Digest: v2Digest, Size: 100}
+	if err := policy.verifyTarget(context.Background(), gun+":v1", v2Desc); err == nil {
+		t.Fatal("expected verifyTarget to reject v2 content served under the v1 ref")
+	}
+}
+
+func TestResolveSignedTargetThresholdNotMet(t *testing.T) {
+	const gun = "library/redis"
+	priv1, key1 := newTrustKey(t)
+	_, key2 := newTrustKey(t)
+	root := tufRoot{
+		Keys:  map[string]tufKey{"key1": key1, "key2": key2},
+		Roles: map[string]tufRole{"targets": {KeyIDs: []string{"key1", "key2"}, Threshold: 2}},
+	}
+
+	dgst := digest.FromString("content")
+	tf := signTargets(t, map[string]digest.Digest{"v1": dgst}, map[string]ed25519.PrivateKey{"key1": priv1})
+
+	srv := trustServer(t, gun, root, tf, nil)
+	policy := &TrustPolicy{Server: srv.URL, RootDir: t.TempDir()}
+
+	_, err := policy.resolveSignedTarget(context.Background(), gun, gun+":v1")
+	if err == nil {
+		t.Fatal("expected threshold error with 1 of 2 required signatures")
+	}
+	if !errors.Is(err, errdefs.ErrFailedPrecondition) {
+		t.Fatalf("expected ErrFailedPrecondition, got %v", err)
+	}
+}
+
+func TestResolveSignedTargetTamperedMetadataFailsVerification(t *testing.T) {
+	const gun = "library/redis"
+	priv, key := newTrustKey(t)
+	root := tufRoot{
+		Keys:  map[string]tufKey{"key1": key},
+		Roles: map[string]tufRole{"targets": {KeyIDs: []string{"key1"}, Threshold: 1}},
+	}
+
+	dgst := digest.FromString("content")
+	tf := signTargets(t, map[string]digest.Digest{"v1": dgst}, map[string]ed25519.PrivateKey{"key1": priv})
+
+	tampered := digest.FromString("swapped-in-by-a-malicious-registry")
+	srv := trustServer(t, gun, root, tf, func(tf *signedTargetsFile) {
+		entry := tf.Signed.Targets["v1"]
+		entry.Hashes["sha256"] = tampered.Encoded()
+		tf.Signed.Targets["v1"] = entry
+	})
+	policy := &TrustPolicy{Server: srv.URL, RootDir: t.TempDir()}
+
+	_, err := policy.resolveSignedTarget(context.Background(), gun, gun+":v1")
+	if err == nil {
+		t.Fatal("expected tampered targets metadata to fail signature verification")
+	}
+	if !errors.Is(err, errdefs.ErrFailedPrecondition) {
+		t.Fatalf("expected ErrFailedPrecondition, got %v", err)
+	}
+}
+
+func TestRefTarget(t *testing.T) {
+	cases := []struct {
+		ref        string
+		wantGUN    string
+		wantSuffix string
+	}{
+		{"repo:v1", "repo", "v1"},
+		{"repo@sha256:abcd", "repo", "sha256:abcd"},
+		{"localhost:5000/repo:v1", "localhost:5000/repo", "v1"},
+		{"localhost:5000/repo@sha256:abcd", "localhost:5000/repo", "sha256:abcd"},
+		{"repo:v1@sha256:abcd", "repo", "sha256:abcd"},
+		{"repo", "repo", ""},
+	}
+	for _, tc := range cases {
+		gun, suffix := refTarget(tc.ref)
+		if gun != tc.wantGUN || suffix != tc.wantSuffix {
+			t.Errorf("refTarget(%q) = (%q, %q), want (%q, %q)", tc.ref, gun, suffix, tc.wantGUN, tc.wantSuffix)
+		}
+	}
+}